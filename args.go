@@ -4,32 +4,540 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 type Config struct {
+	InputFilePaths []string
+	OutputFilePath string
+	Outputs        map[string]string
+	Providers      []string
+	Write          bool
+	Watch          bool
+	WatchInterval  time.Duration
+	Format         string
+	Target         string
+	Package        string
+	Nullable       string
+	Datetime       string
+	URL            string
+	Project        *ProjectConfig
+}
+
+type ImportConfig struct {
 	InputFilePath  string
 	OutputFilePath string
+	From           string
+}
+
+type MigrateConfig struct {
+	Name           string
+	Dir            string
+	Paths          []string
+	InputFilePaths []string
+	URL            string
+	Project        *ProjectConfig
+}
+
+type DiffConfig struct {
+	OldFilePath string
+	NewFilePath string
+}
+
+// newFlagSet creates the flags of a command, errors are returned to the
+// caller instead of exiting so usage errors get their own exit code
+func newFlagSet(name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sql-mi %s %s\n", name, usage)
+		flags.SetOutput(os.Stderr)
+		flags.PrintDefaults()
+		flags.SetOutput(io.Discard)
+	}
+	return flags
+}
+
+// parseFlags parses the arguments, the flag set prints the usage on errors and
+// --help, flag.ErrHelp is returned as is so the caller can exit successfully.
+// Flags may come after the positional arguments, everything after -- is
+// positional
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	positional := []string{}
+	for len(args) != 0 {
+		err := flags.Parse(args)
+		if err == flag.ErrHelp {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+
+		rest := flags.Args()
+		parsed := len(args) - len(rest)
+		if len(rest) == 0 || (parsed > 0 && args[parsed-1] == "--") {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	// parsing again after -- only sets what flags.Args returns
+	return flags.Parse(append([]string{"--"}, positional...))
+}
+
+func ParseGenerateArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+	providers := ""
+
+	flags := newFlagSet("generate", "[flags] <schema|->")
+	flags.StringVar(
+		&cfg.OutputFilePath,
+		"o",
+		"",
+		"Output file, - for stdout (default schema.sql, or stdout when it is not a terminal)",
+	)
+	flags.StringVar(
+		&providers,
+		"provider",
+		"",
+		"Comma separated list of providers, overrides 'set provider' (e.g. sqlite,postgresql)",
+	)
+	flags.StringVar(&cfg.Target, "target", "sql", "What to generate: sql, go, typescript, jsonschema, dbml or docs")
+	flags.StringVar(&cfg.Format, "format", "markdown", "Format of the docs target: markdown or html")
+	flags.StringVar(&cfg.Package, "package", "models", "Package name of the go target")
+	flags.StringVar(&cfg.Nullable, "nullable", "sql", "How the go target writes @nullable colmuns: sql (sql.NullString) or pointer")
+	flags.StringVar(&cfg.Datetime, "datetime", "string", "TypeScript type of datetime colmuns: string or Date")
+	flags.BoolVar(&cfg.Watch, "watch", false, "Regenerate every time the schema files change")
+	flags.DurationVar(&cfg.WatchInterval, "interval", 500*time.Millisecond, "How often --watch checks the files")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = loadConfigInputs(cfg, flags, *configPath, "sql-mi generate [flags] <schema|->")
+	if err != nil {
+		return cfg, err
+	}
+
+	if len(providers) > 0 {
+		for _, p := range strings.Split(providers, ",") {
+			p = strings.TrimSpace(p)
+			if !isProviderAvailable(p) {
+				return cfg, errors.New(fmt.Sprintf("Error: Provider '%s' not supported", p))
+			}
+			cfg.Providers = append(cfg.Providers, p)
+		}
+	}
+
+	target, exists := targets[cfg.Target]
+	if !exists && cfg.Target != "sql" {
+		return cfg, fmt.Errorf("Error: Unknown target '%s'", cfg.Target)
+	}
+
+	if cfg.Nullable != "sql" && cfg.Nullable != "pointer" {
+		return cfg, fmt.Errorf("Error: Unknown nullable style '%s', expected sql or pointer", cfg.Nullable)
+	}
+
+	if cfg.Format != "markdown" && cfg.Format != "html" {
+		return cfg, fmt.Errorf("Error: Unknown format '%s', expected markdown or html", cfg.Format)
+	}
+
+	if target != nil && target.Files != nil && cfg.OutputFilePath == "-" {
+		return cfg, fmt.Errorf("Error: The %s target writes a directory, use -o <directory>", cfg.Target)
+	}
+
+	if cfg.Datetime != "string" && cfg.Datetime != "Date" {
+		return cfg, fmt.Errorf("Error: Unknown datetime type '%s', expected string or Date", cfg.Datetime)
+	}
+
+	// outputs from the configuration are only used when -o is not given
+	if len(cfg.OutputFilePath) == 0 && len(cfg.Project.Output) > 0 && target == nil {
+		cfg.Outputs = cfg.Project.Output
+		if len(cfg.Providers) == 0 {
+			cfg.Providers = cfg.Project.OutputProviders()
+		}
+	}
+
+	if len(cfg.OutputFilePath) == 0 {
+		// piped output goes to stdout, except for several providers which
+		// need a file each
+		if target != nil && target.Files != nil {
+			cfg.OutputFilePath = target.DefaultOutput
+		} else if !isTerminal(os.Stdout) && (len(cfg.Providers) < 2 || target != nil) {
+			cfg.OutputFilePath = "-"
+		} else if target != nil {
+			cfg.OutputFilePath = target.DefaultOutput
+		} else {
+			cfg.OutputFilePath = "schema.sql"
+		}
+	}
+
+	if cfg.Watch && cfg.InputFilePaths[0] == "-" {
+		return cfg, errors.New("Error: --watch can not be used when reading from stdin")
+	}
+
+	if cfg.OutputFilePath == "-" && len(cfg.Providers) > 1 && len(cfg.Outputs) == 0 && target == nil {
+		return cfg, errors.New("Error: Several providers can not be written to stdout, use -o <file>")
+	}
+
+	return cfg, nil
+}
+
+func ParseValidateArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("validate", "[flags] <schema|->")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	flags.StringVar(&cfg.Format, "format", "text", "Output format of the diagnostics: text, json or sarif")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.Format != "text" && cfg.Format != "json" && cfg.Format != "sarif" {
+		return cfg, fmt.Errorf("Error: Unknown format '%s', expected text, json or sarif", cfg.Format)
+	}
+
+	err = loadConfigInputs(cfg, flags, *configPath, "sql-mi validate [flags] <schema|->")
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadConfigInputs loads the project configuration and takes the schema from
+// the command line, or from the configuration when none is given
+func loadConfigInputs(cfg *Config, flags *flag.FlagSet, configPath string, usage string) error {
+	project, err := LoadProjectConfig(configPath)
+	if err != nil {
+		return err
+	}
+	cfg.Project = project
+
+	if flags.NArg() > 1 {
+		return errors.New("Error: Please provide one schema file.\nUsage: " + usage)
+	}
+
+	if flags.NArg() == 1 {
+		cfg.InputFilePaths = []string{flags.Arg(0)}
+		return nil
+	}
+
+	if len(project.Input) == 0 {
+		return errors.New("Error: Please provide a schema file, or an input in sqlmi.toml.\nUsage: " + usage)
+	}
+
+	cfg.InputFilePaths = project.Input
+	return nil
+}
+
+func ParseFmtArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("fmt", "[-w] <schema|->")
+	flags.BoolVar(&cfg.Write, "w", false, "Write the result back to the schema file instead of stdout")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if flags.NArg() != 1 {
+		return cfg, errors.New("Error: Please provide one schema file.\nUsage: sql-mi fmt [-w] <schema|->")
+	}
+
+	cfg.InputFilePaths = []string{flags.Arg(0)}
+
+	if cfg.Write && cfg.InputFilePaths[0] == "-" {
+		return cfg, errors.New("Error: -w can not be used when reading from stdin")
+	}
+
+	return cfg, nil
+}
+
+func ParseImportArgs(args []string) (*ImportConfig, error) {
+	cfg := &ImportConfig{}
+
+	flags := newFlagSet("import", "[flags] <file|->")
+	flags.StringVar(&cfg.From, "from", "", "Format of the file: dbml (default from the file extension)")
+	flags.StringVar(&cfg.OutputFilePath, "o", "-", "Output schema file, - for stdout")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if flags.NArg() != 1 {
+		return cfg, errors.New("Error: Please provide one file to import.\nUsage: sql-mi import [flags] <file|->")
+	}
+	cfg.InputFilePath = flags.Arg(0)
+
+	if len(cfg.From) == 0 {
+		cfg.From = strings.TrimPrefix(filepath.Ext(cfg.InputFilePath), ".")
+	}
+
+	if _, exists := importers[cfg.From]; !exists {
+		return cfg, fmt.Errorf("Error: Unknown format '%s', use --from dbml or prisma", cfg.From)
+	}
+
+	return cfg, nil
+}
+
+func ParseDiffArgs(args []string) (*DiffConfig, error) {
+	cfg := &DiffConfig{}
+
+	flags := newFlagSet("diff", "<old schema> <new schema>")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if flags.NArg() != 2 {
+		return cfg, errors.New("Error: Please provide two schema files.\nUsage: sql-mi diff <old schema> <new schema>")
+	}
+
+	cfg.OldFilePath = flags.Arg(0)
+	cfg.NewFilePath = flags.Arg(1)
+	return cfg, nil
 }
 
-func ParseArgs() (*Config, error) {
+func ParseDiagramArgs(args []string) (*Config, error) {
 	cfg := &Config{}
 
-	flag.StringVar(&cfg.OutputFilePath, "o", "schema.sql", "Output file")
-	flag.Parse()
+	flags := newFlagSet("diagram", "[flags] <schema|->")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	flags.StringVar(&cfg.Format, "format", "mermaid", "Diagram format: mermaid or dot")
+	flags.StringVar(&cfg.OutputFilePath, "o", "-", "Output file, - for stdout")
 
-	args := flag.Args()
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
 
-	if len(args) == 0 {
-		return cfg, errors.New(fmt.Sprintf(
-			"Error: Please provide a filename as an argument.\nUsage: ./script <filename>\n",
-		))
+	if _, exists := diagramFormats[cfg.Format]; !exists {
+		return cfg, fmt.Errorf("Error: Unknown format '%s', expected mermaid or dot", cfg.Format)
 	}
 
-	cfg.InputFilePath = args[0]
+	err = loadConfigInputs(cfg, flags, *configPath, "sql-mi diagram [flags] <schema|->")
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func ParseParseArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("parse", "[flags] <schema|->")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	flags.StringVar(&cfg.Format, "format", "json", "Output format: json")
+	flags.StringVar(&cfg.OutputFilePath, "o", "-", "Output file, - for stdout")
 
-	if cfg.OutputFilePath == "schema.sql" && len(args) > 2 {
-		return cfg, errors.New(fmt.Sprintf("Usage: ./script -o <outputfile> <sourcefile>"))
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.Format != "json" {
+		return cfg, fmt.Errorf("Error: Unknown format '%s', expected json", cfg.Format)
+	}
+
+	err = loadConfigInputs(cfg, flags, *configPath, "sql-mi parse [flags] <schema|->")
+	if err != nil {
+		return cfg, err
 	}
 
 	return cfg, nil
 }
+
+func ParsePushArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("push", "[flags] <schema|->")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	flags.StringVar(&cfg.URL, "url", "", "Database url, overrides 'set url' (e.g. file:app.db)")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = loadConfigInputs(cfg, flags, *configPath, "sql-mi push [flags] <schema|->")
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func ParseMigrateNewArgs(args []string) (*MigrateConfig, error) {
+	cfg := &MigrateConfig{}
+
+	flags := newFlagSet("migrate new", "[flags] <name>")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	schemaPath := flags.String("schema", "", "Schema file (default the input of sqlmi.toml)")
+	flags.StringVar(&cfg.Dir, "dir", "", "Migrations directory (default the migrations of sqlmi.toml, or migrations)")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if flags.NArg() != 1 {
+		return cfg, errors.New("Error: Please provide a name for the migration.\nUsage: sql-mi migrate new [flags] <name>")
+	}
+	cfg.Name = flags.Arg(0)
+
+	cfg.Project, err = LoadProjectConfig(*configPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	if len(*schemaPath) > 0 {
+		cfg.InputFilePaths = []string{*schemaPath}
+	} else if len(cfg.Project.Input) > 0 {
+		cfg.InputFilePaths = cfg.Project.Input
+	} else {
+		return cfg, errors.New("Error: Please provide a schema with --schema, or an input in sqlmi.toml.\nUsage: sql-mi migrate new [flags] <name>")
+	}
+
+	cfg.Dir = migrationsDir(cfg.Dir, cfg.Project)
+	return cfg, nil
+}
+
+func ParseMigrateApplyArgs(args []string) (*MigrateConfig, error) {
+	cfg := &MigrateConfig{}
+
+	flags := newFlagSet("migrate apply", "[flags] [migrations]")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	flags.StringVar(&cfg.URL, "url", "", "Database url, overrides 'set url' of the schema in sqlmi.toml (e.g. file:app.db)")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.Project, err = LoadProjectConfig(*configPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.Paths = flags.Args()
+	if len(cfg.Paths) == 0 {
+		cfg.Paths = []string{migrationsDir("", cfg.Project)}
+	}
+
+	return cfg, nil
+}
+
+func ParseDriftArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("drift", "[flags] <schema|->")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+	flags.StringVar(&cfg.URL, "url", "", "Database url, overrides 'set url' (e.g. file:app.db)")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = loadConfigInputs(cfg, flags, *configPath, "sql-mi drift [flags] <schema|->")
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func ParseIntrospectArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("introspect", "[flags] --url <url>")
+	flags.StringVar(&cfg.URL, "url", "", "Database url (e.g. file:app.db)")
+	flags.StringVar(&cfg.OutputFilePath, "o", "-", "Output schema file, - for stdout")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if flags.NArg() != 0 {
+		return cfg, errors.New("Error: introspect takes no arguments.\nUsage: sql-mi introspect [flags] --url <url>")
+	}
+
+	if len(cfg.URL) == 0 {
+		return cfg, errors.New("Error: Please provide the database with --url.\nUsage: sql-mi introspect [flags] --url <url>")
+	}
+
+	return cfg, nil
+}
+
+// migrationsDir gives the directory from the flag, then from the project
+// configuration
+func migrationsDir(flagDir string, project *ProjectConfig) string {
+	if len(flagDir) > 0 {
+		return flagDir
+	}
+	if len(project.Migrations) > 0 {
+		return project.Migrations
+	}
+	return "migrations"
+}
+
+func ParseGrammarArgs(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	flags := newFlagSet("grammar", "[flags]")
+	flags.StringVar(&cfg.Format, "format", "textmate", "Grammar format: "+strings.Join(grammarFormats, ", "))
+	flags.StringVar(&cfg.OutputFilePath, "o", "-", "Output file, - for stdout")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if flags.NArg() != 0 {
+		return cfg, errors.New("Error: grammar takes no arguments.\nUsage: sql-mi grammar [flags]")
+	}
+
+	if cfg.Format != "textmate" {
+		return cfg, fmt.Errorf("Error: Unknown format '%s', expected %s", cfg.Format, strings.Join(grammarFormats, ", "))
+	}
+
+	return cfg, nil
+}
+
+// outputs declared in the project configuration are used as is, otherwise
+// with several providers every output gets the provider name before its
+// extension, schema.sql becomes schema.sqlite.sql and schema.postgresql.sql
+func outputFilePathFor(cfg *Config, provider string) string {
+	if path, exists := cfg.Outputs[provider]; exists {
+		return path
+	}
+
+	if len(cfg.Providers) < 2 {
+		return cfg.OutputFilePath
+	}
+
+	path := cfg.OutputFilePath
+	dot := strings.LastIndex(path, ".")
+	slash := strings.LastIndexAny(path, `/\`)
+	if dot <= slash+1 {
+		return path + "." + provider
+	}
+
+	return path[:dot] + "." + provider + path[dot:]
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type attributeArity struct {
+	Count   int
+	Strings bool
+}
+
+// the parameters each attribute takes, what the parser checks for a schema
+// has to be checked for a json ast as well
+var colmunAttributeArity = map[string]attributeArity{
+	"id":             {0, false},
+	"auto_increment": {0, false},
+	"unique":         {0, false},
+	"nullable":       {0, false},
+	"default":        {1, false},
+	"raw":            {1, true},
+	"renamedFrom":    {1, true},
+}
+
+var tableAttributeArity = map[string]attributeArity{
+	"renamedFrom": {1, true},
+}
+
+// FormatASTJSON writes the ast as json, maps are written with sorted keys so
+// the same schema always gives the same output
+func FormatASTJSON(ast *AST) (string, error) {
+	content, err := json.MarshalIndent(ast, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+// isASTJSON tells a json ast from a schema, schemas never start with {
+func isASTJSON(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte("{"))
+}
+
+// ReadASTJSON reads an ast written by FormatASTJSON, or by other tools, and
+// checks what the parser would otherwise have made sure of
+func ReadASTJSON(content []byte, file string) (*AST, error) {
+	parsed := &AST{}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("Error: Invalid ast json in '%s': %v", file, err)
+	}
+
+	if parsed.Configuration == nil {
+		parsed.Configuration = map[string]string{}
+	}
+	if len(parsed.Configuration["provider"]) == 0 {
+		parsed.Configuration["provider"] = "sqlite"
+	}
+	if !isProviderAvailable(parsed.Configuration["provider"]) {
+		return nil, fmt.Errorf("Error: Provider '%s' not supported", parsed.Configuration["provider"])
+	}
+
+	// the json file is what --watch has to look at
+	if len(file) > 0 && file != "-" {
+		parsed.Files = []string{file}
+	} else {
+		parsed.Files = []string{}
+	}
+	if parsed.Settings == nil {
+		parsed.Settings = []*SettingAST{}
+	}
+	if parsed.Imports == nil {
+		parsed.Imports = []*ImportAST{}
+	}
+	if parsed.Tables == nil {
+		parsed.Tables = []*TabelAST{}
+	}
+
+	for i, table := range parsed.Tables {
+		if table == nil || !isValidTableName(table.Name) {
+			return nil, fmt.Errorf("Error: Table %d has an invalid name", i)
+		}
+		for _, other := range parsed.Tables[:i] {
+			if other.Name == table.Name {
+				return nil, fmt.Errorf("Error: Table with name '%s' declared twice", table.Name)
+			}
+		}
+
+		err := normalizeTableJSON(table, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// references are checked once every table is known
+	for _, table := range parsed.Tables {
+		for _, ref := range table.References {
+			if findColmun(table, ref.SourceCol) == nil {
+				return nil, fmt.Errorf("Error: no such col '%s' on table '%s'", ref.SourceCol, table.Name)
+			}
+
+			target := findTable(parsed, ref.TargetTable)
+			if target == nil {
+				return nil, fmt.Errorf("Error: no such table '%s'", ref.TargetTable)
+			}
+			if findColmun(target, ref.TargetCol) == nil {
+				return nil, fmt.Errorf("Error: no such col '%s' on table '%s'", ref.TargetCol, target.Name)
+			}
+		}
+	}
+
+	return parsed, nil
+}
+
+func normalizeTableJSON(table *TabelAST, file string) error {
+	if table.Colmuns == nil {
+		table.Colmuns = []*ColmunAST{}
+	}
+	if table.References == nil {
+		table.References = []*ReferenceAST{}
+	}
+	if table.Attributes == nil {
+		table.Attributes = &AttributesAST{}
+	}
+	for name, attr := range *table.Attributes {
+		if attr == nil {
+			return fmt.Errorf("Error: Attribute '%s' of table '%s' is null", name, table.Name)
+		}
+		attr.Name = name
+		if attr.Values == nil {
+			attr.Values = []*AttributeArgAST{}
+		}
+
+		owner := fmt.Sprintf("table '%s'", table.Name)
+		arity, exists := tableAttributeArity[name]
+		if !exists {
+			return invalidAttributeJSON(attr, file, fmt.Sprintf("Unknown attribute @%s on %s", name, owner))
+		}
+		err := checkAttributeJSON(attr, arity, owner, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, colmun := range table.Colmuns {
+		if colmun == nil || !isValidColmunName(colmun.Name) {
+			return fmt.Errorf("Error: Colmun %d of table '%s' has an invalid name", i, table.Name)
+		}
+		for _, other := range table.Colmuns[:i] {
+			if other.Name == colmun.Name {
+				return fmt.Errorf("Error: Colmun with name '%s' declared twice in table '%s'", colmun.Name, table.Name)
+			}
+		}
+
+		if colmun.Type_params == nil {
+			colmun.Type_params = []string{}
+		}
+		for _, param := range colmun.Type_params {
+			if _, err := strconv.Atoi(param); err != nil {
+				return fmt.Errorf("Error: Colmun '%s.%s' has type parameter '%s', expected a number", table.Name, colmun.Name, param)
+			}
+		}
+		if colmun.Attributes == nil {
+			colmun.Attributes = &AttributesAST{}
+		}
+
+		for name, attr := range *colmun.Attributes {
+			if attr == nil {
+				return fmt.Errorf("Error: Attribute '%s' of colmun '%s' is null", name, colmun.Name)
+			}
+			attr.Name = name
+			if attr.Values == nil {
+				attr.Values = []*AttributeArgAST{}
+			}
+
+			owner := fmt.Sprintf("colmun '%s.%s'", table.Name, colmun.Name)
+			arity, exists := colmunAttributeArity[name]
+			if providerName, isOverride := strings.CutPrefix(name, "db."); isOverride {
+				if !isProviderAvailable(providerName) {
+					return invalidAttributeJSON(attr, file, fmt.Sprintf("Unknown provider '%s' in @%s of %s", providerName, name, owner))
+				}
+				arity, exists = attributeArity{1, false}, true
+			}
+			if !exists {
+				return invalidAttributeJSON(attr, file, fmt.Sprintf("Unknown attribute @%s on %s", name, owner))
+			}
+
+			err := checkAttributeJSON(attr, arity, owner, file)
+			if err != nil {
+				return err
+			}
+		}
+
+		if colmun.Data_type == "raw" {
+			if _, exists := (*colmun.Attributes)["raw"]; !exists {
+				return fmt.Errorf("Error: Colmun '%s.%s' has a raw type without a raw attribute", table.Name, colmun.Name)
+			}
+		}
+	}
+
+	for _, ref := range table.References {
+		if ref == nil {
+			return fmt.Errorf("Error: Table '%s' has a null reference", table.Name)
+		}
+	}
+
+	return nil
+}
+
+// checkAttributeJSON makes sure an attribute has the parameters the parser
+// would have required, so the generators can rely on them
+func checkAttributeJSON(attr *AttributeAST, arity attributeArity, owner string, file string) error {
+	if len(attr.Values) != arity.Count {
+		return invalidAttributeJSON(attr, file, fmt.Sprintf("@%s of %s takes %d parameters, got %d", attr.Name, owner, arity.Count, len(attr.Values)))
+	}
+	for _, arg := range attr.Values {
+		if arg == nil || (arg.Type != "string" && arg.Type != "raw") {
+			return invalidAttributeJSON(attr, file, fmt.Sprintf("@%s of %s has a parameter that is not a string or raw value", attr.Name, owner))
+		}
+		if arity.Strings && arg.Type != "string" {
+			return invalidAttributeJSON(attr, file, fmt.Sprintf("@%s of %s expects string values", attr.Name, owner))
+		}
+	}
+	return nil
+}
+
+func invalidAttributeJSON(attr *AttributeAST, file string, msg string) error {
+	pos := attr.Pos
+	if len(pos.File) == 0 {
+		pos.File = file
+	}
+	return &Diagnostic{
+		Kind:     "Syntax",
+		Severity: severityError,
+		Code:     "invalid-attribute",
+		Pos:      pos,
+		End:      nameEnd(pos, "@"+attr.Name),
+		Message:  msg,
+	}
+}
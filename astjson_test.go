@@ -0,0 +1,171 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASTJSONRoundTrip(t *testing.T) {
+	schema := parseSchema(t, "postgresql", `
+/// People who can sign in.
+table users @renamedFrom("accounts")
+	id int @id @auto_increment
+	email string(255) @unique @default("a@b.c")
+	data json @db.postgresql(`+"`JSONB`"+`) @nullable
+	span `+"`INTERVAL`"+`
+end
+table posts
+	id int @id
+	author int @reference("users", "id") @onDelete("CASCADE")
+	price decimal(10, 2) @renamedFrom("cost")
+end
+`)
+
+	content, err := FormatASTJSON(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadASTJSON([]byte(content), "schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read.Files) != 1 || read.Files[0] != "schema.json" {
+		t.Errorf("files %q, want the json file --watch has to look at", read.Files)
+	}
+	read.Files = schema.Files
+
+	again, err := FormatASTJSON(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != content {
+		t.Errorf("json changed after reading it back:\n%s\nwant:\n%s", again, content)
+	}
+
+	sql, err := GenerateSQL(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readSQL, err := GenerateSQL(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readSQL != sql {
+		t.Errorf("sql from the json:\n%s\nwant:\n%s", readSQL, sql)
+	}
+}
+
+func TestReadASTJSONDefaults(t *testing.T) {
+	read, err := ReadASTJSON([]byte(`{"tables": [{"name": "users", "columns": [{"name": "id", "type": "int"}]}]}`), "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colmun := read.Tables[0].Colmuns[0]
+	if read.Configuration["provider"] != "sqlite" || len(read.Files) != 0 || colmun.Attributes == nil || colmun.Type_params == nil {
+		t.Errorf("missing fields were not filled in: %+v %+v", read, colmun)
+	}
+}
+
+func TestReadASTJSONErrors(t *testing.T) {
+	colmun := func(json string) string {
+		return `{"tables": [{"name": "users", "columns": [` + json + `]}]}`
+	}
+
+	tests := []struct {
+		name  string
+		json  string
+		error string
+	}{
+		{"malformed", `{"tables": [`, "Invalid ast json in 'schema.json'"},
+		{"unknown field", `{"tabels": []}`, "unknown field"},
+		{"unknown provider", `{"configuration": {"provider": "oracle"}}`, "Provider 'oracle' not supported"},
+		{"invalid table name", `{"tables": [{"name": "my table"}]}`, "Table 0 has an invalid name"},
+		{"table declared twice", `{"tables": [{"name": "users"}, {"name": "users"}]}`, "Table with name 'users' declared twice"},
+		{"invalid colmun name", colmun(`{"name": "first name", "type": "int"}`), "Colmun 0 of table 'users' has an invalid name"},
+		{"colmun declared twice", colmun(`{"name": "id", "type": "int"}, {"name": "id", "type": "int"}`), "Colmun with name 'id' declared twice"},
+		{"type parameter", colmun(`{"name": "name", "type": "string", "typeParams": ["long"]}`), "has type parameter 'long', expected a number"},
+		{"unknown attribute", colmun(`{"name": "id", "type": "int", "attributes": {"primary": {}}}`), "Unknown attribute @primary on colmun 'users.id'"},
+		{"unknown provider override", colmun(`{"name": "id", "type": "int", "attributes": {"db.oracle": {"args": [{"value": "NUMBER", "type": "raw"}]}}}`), "Unknown provider 'oracle' in @db.oracle"},
+		{"too many parameters", colmun(`{"name": "id", "type": "int", "attributes": {"id": {"args": [{"value": "x", "type": "string"}]}}}`), "@id of colmun 'users.id' takes 0 parameters, got 1"},
+		{"missing parameter", colmun(`{"name": "id", "type": "int", "attributes": {"default": {}}}`), "@default of colmun 'users.id' takes 1 parameters, got 0"},
+		{"parameter type", colmun(`{"name": "id", "type": "int", "attributes": {"default": {"args": [{"value": "1", "type": "number"}]}}}`), "has a parameter that is not a string or raw value"},
+		{"raw where a string is expected", colmun(`{"name": "id", "type": "int", "attributes": {"renamedFrom": {"args": [{"value": "uid", "type": "raw"}]}}}`), "@renamedFrom of colmun 'users.id' expects string values"},
+		{"null attribute", colmun(`{"name": "id", "type": "int", "attributes": {"id": null}}`), "Attribute 'id' of colmun 'id' is null"},
+		{"raw type without its attribute", colmun(`{"name": "span", "type": "raw"}`), "Colmun 'users.span' has a raw type without a raw attribute"},
+		{"unknown table attribute", `{"tables": [{"name": "users", "attributes": {"unique": {}}}]}`, "Unknown attribute @unique on table 'users'"},
+		{"null reference", `{"tables": [{"name": "users", "references": [null]}]}`, "Table 'users' has a null reference"},
+		{"reference to an unknown table", `{"tables": [{"name": "posts", "columns": [{"name": "author", "type": "int"}], "references": [{"targetTable": "users", "targetColumn": "id", "sourceColumn": "author"}]}]}`, "no such table 'users'"},
+		{"reference from an unknown colmun", `{"tables": [{"name": "posts", "references": [{"targetTable": "posts", "targetColumn": "id", "sourceColumn": "author"}]}]}`, "no such col 'author' on table 'posts'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadASTJSON([]byte(test.json), "schema.json")
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+// a json ast goes through the same checks as a schema
+func TestGenerateFromASTJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		code int
+		sql  string
+	}{
+		{
+			"valid",
+			`{"tables": [{"name": "users", "columns": [{"name": "id", "type": "int", "attributes": {"id": {}}}]}]}`,
+			exitOK,
+			"CREATE TABLE users (\n\tid INTEGER PRIMARY KEY NOT NULL\n);\n\n",
+		},
+		{
+			"invalid default",
+			`{"tables": [{"name": "users", "columns": [{"name": "id", "type": "int", "attributes": {"default": {"args": [{"value": "one", "type": "string"}]}}}]}]}`,
+			exitSchemaError,
+			"",
+		},
+		{
+			"unknown type",
+			`{"tables": [{"name": "users", "columns": [{"name": "id", "type": "integer"}]}]}`,
+			exitSchemaError,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := exitOK
+			out := withStdio(t, test.json, func() {
+				code = Run([]string{"generate", "-"})
+			})
+			if code != test.code || out != test.sql {
+				t.Errorf("exit code %d with:\n%s\nwant %d with:\n%s", code, out, test.code, test.sql)
+			}
+		})
+	}
+}
+
+func TestIsASTJSON(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{`{"tables": []}`, true},
+		{"\n  {}", true},
+		{"set provider sqlite\n", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isASTJSON([]byte(test.content)); got != test.want {
+			t.Errorf("isASTJSON(%q) = %v, want %v", test.content, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var defaultCheckers map[string]func(value string) error
+
+var datetimeFormats = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+var uuidRegex = regexp.MustCompile(
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+)
+
+func initCheckValues() {
+	defaultCheckers = map[string]func(string) error{
+		"int":         checkIntDefault,
+		"bigint":      checkIntDefault,
+		"smallint":    checkSmallintDefault,
+		"float":       checkFloatDefault,
+		"decimal":     checkFloatDefault,
+		"bool":        checkBoolDefault,
+		"boolean":     checkBoolDefault,
+		"datetime":    checkDatetimeDefault,
+		"date":        checkDateDefault,
+		"time":        checkTimeDefault,
+		"timestamptz": checkTimestamptzDefault,
+		"uuid":        checkUUIDDefault,
+		"json":        checkJSONDefault,
+	}
+}
+
+// Check runs the semantic checks on a parsed schema, it does not stop on the
+// first error so every problem can be reported at once
+func Check(ast *AST) []error {
+	initValues()
+	initCheckValues()
+
+	errs := []error{}
+	for _, table := range ast.Tables {
+		for _, colmun := range table.Colmuns {
+			err := checkType(colmun)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			err = checkTypeParams(colmun)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			err = checkDefault(colmun)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+func checkDefault(colmun *ColmunAST) error {
+	attr, exists := (*colmun.Attributes)["default"]
+	if !exists || len(attr.Values) != 1 {
+		return nil
+	}
+
+	// raw expressions like `CURRENT_TIMESTAMP` are passed through as is
+	arg := attr.Values[0]
+	if arg.Type == "raw" {
+		return nil
+	}
+
+	checker, exists := defaultCheckers[colmun.Data_type]
+	if !exists {
+		checker = func(string) error { return nil }
+	}
+
+	err := checker(arg.Value)
+	if err == nil {
+		err = checkDefaultLength(colmun, arg.Value)
+	}
+	if err != nil {
+		return createTypeError(
+			"invalid-default",
+			fmt.Sprintf(
+				"Invalid default value \"%s\" for colmun '%s' of type %s: %v",
+				arg.Value,
+				colmun.Name,
+				colmun.Data_type,
+				err,
+			),
+			arg.Pos,
+			len(arg.Value)+2,
+		)
+	}
+
+	return nil
+}
+
+func checkType(colmun *ColmunAST) error {
+	if colmun.Data_type == "raw" {
+		return nil
+	}
+
+	_, isType := types[colmun.Data_type]
+	_, isParamType := paramTypes[colmun.Data_type]
+
+	if !isType && isParamType && len(colmun.Type_params) == 0 {
+		return createTypeError(
+			"invalid-type-params",
+			fmt.Sprintf("Data type '%s' requires parameters", colmun.Data_type),
+			colmun.Pos,
+			len(colmun.Name),
+		)
+	}
+
+	if !isType && !isParamType {
+		return createTypeError(
+			"unknown-type",
+			fmt.Sprintf("Unknown data type '%s' for colmun '%s'", colmun.Data_type, colmun.Name),
+			colmun.Pos,
+			len(colmun.Name),
+		)
+	}
+
+	return nil
+}
+
+func checkTypeParams(colmun *ColmunAST) error {
+	if len(colmun.Type_params) == 0 {
+		return nil
+	}
+
+	paramType, exists := paramTypes[colmun.Data_type]
+	if !exists {
+		return createTypeError(
+			"invalid-type-params",
+			fmt.Sprintf("Data type '%s' takes no parameters", colmun.Data_type),
+			colmun.Pos,
+			len(colmun.Name),
+		)
+	}
+
+	count := len(colmun.Type_params)
+	if count < paramType.Min || count > paramType.Max {
+		expected := fmt.Sprintf("%d", paramType.Min)
+		if paramType.Min != paramType.Max {
+			expected = fmt.Sprintf("%d to %d", paramType.Min, paramType.Max)
+		}
+		return createTypeError(
+			"invalid-type-params",
+			fmt.Sprintf(
+				"Data type '%s' takes %s parameters, got %d",
+				colmun.Data_type,
+				expected,
+				count,
+			),
+			colmun.Pos,
+			len(colmun.Name),
+		)
+	}
+
+	return nil
+}
+
+// string(n) and char(n) defaults must fit in n characters
+func checkDefaultLength(colmun *ColmunAST, value string) error {
+	if colmun.Data_type != "string" && colmun.Data_type != "char" {
+		return nil
+	}
+
+	if len(colmun.Type_params) != 1 {
+		return nil
+	}
+
+	length, err := strconv.Atoi(colmun.Type_params[0])
+	if err != nil {
+		return nil
+	}
+
+	if len([]rune(value)) > length {
+		return fmt.Errorf("longer than %d characters", length)
+	}
+	return nil
+}
+
+func checkIntDefault(value string) error {
+	_, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("expected an integer")
+	}
+	return nil
+}
+
+func checkSmallintDefault(value string) error {
+	_, err := strconv.ParseInt(value, 10, 16)
+	if err != nil {
+		return fmt.Errorf("expected an integer between -32768 and 32767")
+	}
+	return nil
+}
+
+func checkFloatDefault(value string) error {
+	_, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("expected a number")
+	}
+	return nil
+}
+
+func checkBoolDefault(value string) error {
+	switch value {
+	case "true", "false", "1", "0":
+		return nil
+	}
+	return fmt.Errorf("expected true, false, 1 or 0")
+}
+
+func checkDatetimeDefault(value string) error {
+	for _, format := range datetimeFormats {
+		_, err := time.Parse(format, value)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a date like 2006-01-02 or 2006-01-02 15:04:05")
+}
+
+func checkDateDefault(value string) error {
+	_, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return fmt.Errorf("expected a date like 2006-01-02")
+	}
+	return nil
+}
+
+func checkTimeDefault(value string) error {
+	for _, format := range []string{"15:04:05", "15:04"} {
+		_, err := time.Parse(format, value)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a time like 15:04:05")
+}
+
+func checkTimestamptzDefault(value string) error {
+	for _, format := range []string{time.RFC3339, "2006-01-02 15:04:05Z07:00"} {
+		_, err := time.Parse(format, value)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a timestamp with a time zone like 2006-01-02T15:04:05Z")
+}
+
+func checkUUIDDefault(value string) error {
+	if !uuidRegex.MatchString(value) {
+		return fmt.Errorf("expected a uuid")
+	}
+	return nil
+}
+
+func checkJSONDefault(value string) error {
+	if !json.Valid([]byte(value)) {
+		return fmt.Errorf("expected valid json")
+	}
+	return nil
+}
+
+func createTypeError(code string, msg string, pos Position, length int) error {
+	return &Diagnostic{
+		Kind:     "Type",
+		Severity: severityError,
+		Code:     code,
+		Pos:      pos,
+		End:      Position{pos.File, pos.Line, pos.Col + length},
+		Message:  msg,
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkSchema parses a schema and gives the messages of its check errors
+func checkSchema(t *testing.T, schema string) []string {
+	t.Helper()
+
+	messages := []string{}
+	for _, err := range Check(parseSchema(t, "sqlite", schema)) {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+func TestCheckDefault(t *testing.T) {
+	tests := []struct {
+		colmun string
+		error  string
+	}{
+		{`count int @default("42")`, ""},
+		{`count int @default("-7")`, ""},
+		{`count int @default("abc")`, "expected an integer"},
+		{`count int @default("4.2")`, "expected an integer"},
+		{`price float @default("4.2")`, ""},
+		{`price float @default("cheap")`, "expected a number"},
+		{`active bool @default("true")`, ""},
+		{`active bool @default("0")`, ""},
+		{`active bool @default("yes")`, "expected true, false, 1 or 0"},
+		{`created datetime @default("2024-01-02")`, ""},
+		{`created datetime @default("2024-01-02 03:04:05")`, ""},
+		{`created datetime @default("2024-01-02T03:04:05Z")`, ""},
+		{`created datetime @default("yesterday")`, "expected a date"},
+		{`name string @default("anything")`, ""},
+		{`name string(3) @default("abc")`, ""},
+		{`name string(3) @default("abcd")`, "longer than 3 characters"},
+		{`total bigint @default("9000000000")`, ""},
+		{`small smallint @default("32767")`, ""},
+		{`small smallint @default("40000")`, "between -32768 and 32767"},
+		{`price decimal @default("19.99")`, ""},
+		{`day date @default("2024-01-02")`, ""},
+		{`day date @default("2024-01-02 03:04:05")`, "expected a date like 2006-01-02"},
+		{`at time @default("03:04")`, ""},
+		{`at time @default("3 o'clock")`, "expected a time"},
+		{`at timestamptz @default("2024-01-02T03:04:05+02:00")`, ""},
+		{`at timestamptz @default("2024-01-02 03:04:05")`, "with a time zone"},
+		{`key uuid @default("123e4567-e89b-12d3-a456-426614174000")`, ""},
+		{`key uuid @default("123")`, "expected a uuid"},
+		{`data json @default("[1, 2, {}]")`, ""},
+		{`data json @default("{a: 1}")`, "expected valid json"},
+		{"created datetime @default(`CURRENT_TIMESTAMP`)", ""},
+		{"count int @default(`1 + 1`)", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.colmun, func(t *testing.T) {
+			errs := checkSchema(t, "table t\n\tid int @id\n\t"+test.colmun+"\nend\n")
+
+			if len(test.error) == 0 {
+				if len(errs) != 0 {
+					t.Errorf("unexpected errors %q", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0], test.error) {
+				t.Errorf("errors %q, want one containing %q", errs, test.error)
+			}
+		})
+	}
+}
+
+// every invalid default is reported, not only the first one
+func TestCheckReportsEveryDefault(t *testing.T) {
+	errs := checkSchema(t, `
+table t
+	id int @id @default("one")
+	price float @default("two")
+	active bool @default("three")
+end
+`)
+	if len(errs) != 3 {
+		t.Fatalf("errors %q, want 3", errs)
+	}
+	if !strings.Contains(errs[0], `Invalid default value "one" for colmun 'id' of type int`) {
+		t.Errorf("error %q does not name the value, colmun and type", errs[0])
+	}
+}
+
+func TestCheckDefaultPosition(t *testing.T) {
+	errs := Check(parseSchema(t, "sqlite", "table t\n\tcount int @default(\"abc\")\nend\n"))
+	if len(errs) != 1 {
+		t.Fatalf("errors %v, want 1", errs)
+	}
+
+	diagnostic, ok := errs[0].(*Diagnostic)
+	if !ok {
+		t.Fatalf("error %T is not a diagnostic", errs[0])
+	}
+	if diagnostic.Code != "invalid-default" || diagnostic.Kind != "Type" {
+		t.Errorf("diagnostic %s/%s, want Type/invalid-default", diagnostic.Kind, diagnostic.Code)
+	}
+	// the schema starts with the set provider line
+	if diagnostic.Pos.Line != 3 || diagnostic.End.Col-diagnostic.Pos.Col != len(`"abc"`) {
+		t.Errorf("diagnostic at %v to %v, want line 3 spanning the quoted value", diagnostic.Pos, diagnostic.End)
+	}
+}
+
+func TestBoolDefaultForProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		value    string
+		sql      string
+	}{
+		{"sqlite", "true", "DEFAULT 1"},
+		{"sqlite", "0", "DEFAULT 0"},
+		{"mysql", "false", "DEFAULT 0"},
+		{"postgresql", "true", "DEFAULT TRUE"},
+		{"postgresql", "0", "DEFAULT FALSE"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.provider+"/"+test.value, func(t *testing.T) {
+			schema := parseSchema(t, test.provider, "table t\n\tactive bool @default(\""+test.value+"\")\nend\n")
+			sql, err := GenerateSQL(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(sql, test.sql) {
+				t.Errorf("sql does not contain %q:\n%s", test.sql, sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,833 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK          = 0
+	exitSchemaError = 1
+	exitUsageError  = 2
+)
+
+// set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+type Command struct {
+	Name        string
+	Description string
+	Run         func(args []string) int
+}
+
+var commands []*Command
+
+func initCommands() {
+	commands = []*Command{
+		{"generate", "Generate sql from a schema", runGenerate},
+		{"validate", "Check a schema for errors without writing anything", runValidate},
+		{"fmt", "Format a schema", runFmt},
+		{"import", "Convert a schema from another format", runImport},
+		{"diff", "Show the differences between two schemas", runDiff},
+		{"introspect", "Create a schema from an existing database", runIntrospect},
+		{"migrate", "Create and apply migrations", runMigrate},
+		{"push", "Create the tables of a schema in a database", runPush},
+		{"drift", "Compare a schema with the tables of a database", runDrift},
+		{"lsp", "Run a language server over stdio", runLSP},
+		{"diagram", "Draw an entity-relationship diagram of a schema", runDiagram},
+		{"parse", "Print the parsed schema as json", runParse},
+		{"grammar", "Print the syntax highlighting grammar for editors", runGrammar},
+	}
+}
+
+// Run executes the command line and returns the exit code
+func Run(args []string) int {
+	initCommands()
+
+	if len(args) == 0 {
+		printUsage()
+		return exitUsageError
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		if len(args) > 1 {
+			command := findCommand(args[1])
+			if command != nil {
+				return command.Run([]string{"--help"})
+			}
+		}
+		printUsage()
+		return exitOK
+	case "-version", "--version", "version":
+		fmt.Printf("sql-mi %s\n", version)
+		return exitOK
+	}
+
+	command := findCommand(args[0])
+	if command == nil && isLegacyInvocation(args) {
+		// sql-mi -o output.sql input, from before there were commands
+		return runGenerate(args)
+	}
+	if command == nil {
+		fmt.Fprintf(os.Stderr, "Error: Unknown command '%s'\n\n", args[0])
+		printUsage()
+		return exitUsageError
+	}
+
+	return command.Run(args[1:])
+}
+
+func findCommand(name string) *Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+func isLegacyInvocation(args []string) bool {
+	if strings.HasPrefix(args[0], "-") {
+		return true
+	}
+	info, err := os.Stat(args[0])
+	return err == nil && !info.IsDir()
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: sql-mi <command> [flags] [arguments]\n\nCommands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", command.Name, command.Description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'sql-mi <command> --help' for the flags of a command.\n")
+}
+
+// handleArgsError reports an argument parsing error and returns the exit code,
+// asking for --help is not an error
+func handleArgsError(err error) int {
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+	printError(err)
+	return exitUsageError
+}
+
+func printError(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readInput reads a file, or stdin when the path is -
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading stdin: %s", err)
+		}
+		return content, nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("File '%s' does not exist.", path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %s", err)
+	}
+	return content, nil
+}
+
+// loadSchema reads, parses and checks a schema, the path can be a file, a
+// directory, a glob pattern or - for stdin. An ast written by parse
+// --format=json is read instead of parsed
+func loadSchema(path string) (*AST, error) {
+	return loadSchemas([]string{path})
+}
+
+// loadSchemas is loadSchema for several paths parsed into one ast
+func loadSchemas(paths []string) (*AST, error) {
+	var ast *AST
+
+	if len(paths) == 1 && paths[0] == "-" {
+		content, err := readInput(paths[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if isASTJSON(content) {
+			ast, err = ReadASTJSON(content, "-")
+		} else {
+			ast, err = Parse(NewTokenizer(string(content)))
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if len(paths) == 1 && filepath.Ext(paths[0]) == ".json" {
+		content, err := readInput(paths[0])
+		if err != nil {
+			return nil, err
+		}
+
+		ast, err = ReadASTJSON(content, paths[0])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files := []string{}
+		for _, path := range paths {
+			expanded, err := ExpandInputs(path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, expanded...)
+		}
+
+		for _, file := range files {
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				return nil, fmt.Errorf("File '%s' does not exist.", file)
+			}
+		}
+
+		var err error
+		ast, err = ParseFiles(files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	errs := Check(ast)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return ast, nil
+}
+
+func runGenerate(args []string) int {
+	cfg, err := ParseGenerateArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	if cfg.Watch {
+		return watchGenerate(cfg)
+	}
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	outputs, err := generateOutputs(cfg, ast)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	for _, path := range sortedKeys(outputs) {
+		err = writeOutput(path, outputs[path])
+		if err != nil {
+			printError(err)
+			return exitSchemaError
+		}
+	}
+
+	return exitOK
+}
+
+// generateOutputs generates the sql of every requested provider, keyed by
+// the path it should be written to, other targets have a single output
+func generateOutputs(cfg *Config, ast *AST) (map[string]string, error) {
+	if target, exists := targets[cfg.Target]; exists && target.Files != nil {
+		files, err := target.Files(ast, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		outputs := map[string]string{}
+		for name, content := range files {
+			outputs[filepath.Join(cfg.OutputFilePath, name)] = content
+		}
+		return outputs, nil
+	}
+
+	if target, exists := targets[cfg.Target]; exists {
+		content, err := target.Generate(ast, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{cfg.OutputFilePath: content}, nil
+	}
+
+	providers := cfg.Providers
+	if len(providers) == 0 {
+		providers = []string{ast.Configuration["provider"]}
+	}
+
+	outputs := map[string]string{}
+	for _, provider := range providers {
+		sql, err := GenerateSQLForProvider(ast, provider)
+		if err != nil {
+			return nil, err
+		}
+		outputs[outputFilePathFor(cfg, provider)] = sql
+	}
+
+	return outputs, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func runValidate(args []string) int {
+	cfg, err := ParseValidateArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	var diagnostics []*Diagnostic
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		found, ok := AsDiagnostics(err)
+		if !ok {
+			printError(err)
+			return exitSchemaError
+		}
+		diagnostics = found
+	} else {
+		diagnostics = Lint(ast, cfg.Project)
+	}
+	sortDiagnostics(diagnostics)
+
+	switch cfg.Format {
+	case "json", "sarif":
+		format := FormatDiagnosticsJSON
+		if cfg.Format == "sarif" {
+			format = FormatDiagnosticsSARIF
+		}
+
+		out, err := format(diagnostics)
+		if err != nil {
+			printError(err)
+			return exitSchemaError
+		}
+		fmt.Print(out)
+	default:
+		for _, diagnostic := range diagnostics {
+			printError(diagnostic)
+		}
+	}
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == severityError {
+			return exitSchemaError
+		}
+	}
+
+	return exitOK
+}
+
+func runFmt(args []string) int {
+	cfg, err := ParseFmtArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	ast, err := loadSchema(cfg.InputFilePaths[0])
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	formatted := Format(ast)
+
+	if !cfg.Write {
+		fmt.Print(formatted)
+		return exitOK
+	}
+
+	err = writeOutput(cfg.InputFilePaths[0], formatted)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+// formats that can be converted to a schema, what can not be converted is
+// returned as warnings
+var importers = map[string]func(content string) (*AST, []string, error){
+	"dbml":   ImportDBML,
+	"prisma": ImportPrisma,
+}
+
+func runImport(args []string) int {
+	cfg, err := ParseImportArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	content, err := readInput(cfg.InputFilePath)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	ast, warnings, err := importers[cfg.From](string(content))
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	err = writeOutput(cfg.OutputFilePath, Format(ast))
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+func runDiff(args []string) int {
+	cfg, err := ParseDiffArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	oldAst, err := loadSchema(cfg.OldFilePath)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	newAst, err := loadSchema(cfg.NewFilePath)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	fmt.Print(Diff(oldAst, newAst).String())
+	return exitOK
+}
+
+func runIntrospect(args []string) int {
+	cfg, err := ParseIntrospectArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	db, err := openDatabase(cfg.URL)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+	defer db.Close()
+
+	ast, warnings, err := IntrospectSQLite(db, cfg.URL)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	if len(ast.Tables) == 0 {
+		printError(errors.New("Error: The database has no tables"))
+		return exitSchemaError
+	}
+
+	err = writeOutput(cfg.OutputFilePath, Format(ast))
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+// runDrift reports how the database differs from the schema, drift is a
+// schema error so CI and scripts can check for it
+func runDrift(args []string) int {
+	cfg, err := ParseDriftArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	if ast.Configuration["provider"] != string(sqlite) {
+		printError(fmt.Errorf("Error: drift only supports sqlite, the schema uses '%s'", ast.Configuration["provider"]))
+		return exitUsageError
+	}
+
+	url := cfg.URL
+	if len(url) == 0 {
+		url = ast.Configuration["url"]
+	}
+	if len(url) == 0 {
+		printError(errors.New("Error: No database url, use --url or 'set url' in the schema"))
+		return exitUsageError
+	}
+
+	db, err := openDatabase(url)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+	defer db.Close()
+
+	database, warnings, err := IntrospectSQLite(db, url)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	issues, err := Drift(ast, database)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	if len(issues) == 0 {
+		fmt.Println("The database matches the schema")
+		return exitOK
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	return exitSchemaError
+}
+
+const migrateUsage = "Usage: sql-mi migrate new [flags] <name>\n       sql-mi migrate apply [flags] [migrations]\n"
+
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return exitUsageError
+	}
+
+	switch args[0] {
+	case "new":
+		return runMigrateNew(args[1:])
+	case "apply":
+		return runMigrateApply(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: Unknown migrate command '%s'\n", args[0])
+	return exitUsageError
+}
+
+func runMigrateNew(args []string) int {
+	cfg, err := ParseMigrateNewArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	path, err := CreateMigration(cfg.Dir, cfg.Name, ast, time.Now())
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	if len(path) == 0 {
+		fmt.Println("No changes since the last migration")
+		return exitOK
+	}
+
+	fmt.Printf("Created %s\n", path)
+	return exitOK
+}
+
+// runMigrateApply applies the migrations the database does not have yet, the
+// url comes from --url or from the schema of the project
+func runMigrateApply(args []string) int {
+	cfg, err := ParseMigrateApplyArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	migrations, err := FindMigrations(cfg.Paths)
+	if err != nil {
+		printError(err)
+		return exitUsageError
+	}
+
+	url := cfg.URL
+	if len(url) == 0 && len(cfg.Project.Input) > 0 {
+		ast, err := loadSchemas(cfg.Project.Input)
+		if err != nil {
+			printError(err)
+			return exitSchemaError
+		}
+		url = ast.Configuration["url"]
+	}
+	if len(url) == 0 {
+		printError(errors.New("Error: No database url, use --url or 'set url' in the schema"))
+		return exitUsageError
+	}
+
+	db, err := openDatabase(url)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+	defer db.Close()
+
+	applied, err := ApplyMigrations(db, migrations)
+	for _, migration := range applied {
+		fmt.Printf("Applied %s\n", migration.Name)
+	}
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("The database is up to date")
+	}
+	return exitOK
+}
+
+// runPush creates the tables of the schema that are not in the database yet,
+// tables that exist already are left as they are
+func runPush(args []string) int {
+	cfg, err := ParsePushArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	if ast.Configuration["provider"] != string(sqlite) {
+		printError(fmt.Errorf("Error: push only supports sqlite, the schema uses '%s'", ast.Configuration["provider"]))
+		return exitUsageError
+	}
+
+	url := cfg.URL
+	if len(url) == 0 {
+		url = ast.Configuration["url"]
+	}
+	if len(url) == 0 {
+		printError(errors.New("Error: No database url, use --url or 'set url' in the schema"))
+		return exitUsageError
+	}
+
+	db, err := openDatabase(url)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+	defer db.Close()
+
+	existing, err := existingTables(db)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	// the order comes from the whole schema, a missing table may reference
+	// one the database already has
+	missing := *ast
+	missing.Tables = []*TabelAST{}
+	for _, table := range ast.Tables {
+		if existing[table.Name] {
+			fmt.Printf("Skipped table %s, it already exists\n", table.Name)
+			continue
+		}
+		missing.Tables = append(missing.Tables, table)
+	}
+	missing.Tables = inReferenceOrder(ast, missing.Tables)
+
+	if len(missing.Tables) == 0 {
+		fmt.Println("The database is up to date")
+		return exitOK
+	}
+
+	sql, err := GenerateSQL(&missing)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	err = applyStatements(db, splitStatements(sql))
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	for _, table := range missing.Tables {
+		fmt.Printf("Created table %s\n", table.Name)
+	}
+	return exitOK
+}
+
+func runLSP(args []string) int {
+	flags := newFlagSet("lsp", "[flags]")
+	configPath := flags.String("config", "", "Project configuration file (default sqlmi.toml or sqlmi.yaml)")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	project, err := LoadProjectConfig(*configPath)
+	if err != nil {
+		printError(err)
+		return exitUsageError
+	}
+
+	err = RunLSP(os.Stdin, os.Stdout, project)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+func runDiagram(args []string) int {
+	cfg, err := ParseDiagramArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	err = writeOutput(cfg.OutputFilePath, diagramFormats[cfg.Format](ast))
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+func runParse(args []string) int {
+	cfg, err := ParseParseArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	ast, err := loadSchemas(cfg.InputFilePaths)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	content, err := FormatASTJSON(ast)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	err = writeOutput(cfg.OutputFilePath, content)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+func runGrammar(args []string) int {
+	cfg, err := ParseGrammarArgs(args)
+	if err != nil {
+		return handleArgsError(err)
+	}
+
+	initValues()
+
+	grammar, err := GenerateTextMateGrammar()
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	err = writeOutput(cfg.OutputFilePath, grammar)
+	if err != nil {
+		printError(err)
+		return exitSchemaError
+	}
+
+	return exitOK
+}
+
+// writeOutput writes to a file, or stdout when the path is -
+func writeOutput(path string, content string) error {
+	if path == "-" {
+		_, err := os.Stdout.WriteString(content)
+		return err
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("Error creating directory for '%s': %v", path, err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Error creating file '%s': %v", path, err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	if err != nil {
+		return fmt.Errorf("Error writing to file '%s': %v", path, err)
+	}
+
+	return nil
+}
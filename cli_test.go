@@ -0,0 +1,439 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSchema writes a schema into dir and gives its path
+func writeSchema(t *testing.T, dir string, name string, schema string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte(schema), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPush(t *testing.T) {
+	dir := t.TempDir()
+	url := filepath.Join(dir, "app.db")
+	users := `
+table users
+	id int @id
+	name string
+end
+`
+	first := writeSchema(t, dir, "users.sqmi", users)
+	second := writeSchema(t, dir, "posts.sqmi", `
+table posts
+	id int @id
+	author int @reference("users", "id")
+end
+`+users)
+
+	steps := []struct {
+		name   string
+		schema string
+		tables []string
+	}{
+		{"creates the tables", first, []string{"users"}},
+		{"skips existing tables", first, []string{"users"}},
+		{"adds a table referencing an existing one", second, []string{"posts", "users"}},
+	}
+
+	for _, step := range steps {
+		code := runPush([]string{"--url", url, step.schema})
+		if code != exitOK {
+			t.Fatalf("%s: exit code %d", step.name, code)
+		}
+
+		db, err := openDatabase(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables, err := existingTables(db)
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tables) != len(step.tables) {
+			t.Errorf("%s: tables %v, want %v", step.name, tables, step.tables)
+		}
+		for _, table := range step.tables {
+			if !tables[table] {
+				t.Errorf("%s: table %s is missing", step.name, table)
+			}
+		}
+	}
+}
+
+func TestPushErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		schema string
+		args   []string
+		code   int
+	}{
+		{"other provider", "set provider postgresql\ntable users\n\tid int @id\nend\n", []string{"--url", filepath.Join(dir, "app.db")}, exitUsageError},
+		{"no url", "table users\n\tid int @id\nend\n", []string{}, exitUsageError},
+		{"url of another database", "table users\n\tid int @id\nend\n", []string{"--url", "postgres://localhost/app"}, exitSchemaError},
+		{"url from the schema", "set url \"" + filepath.Join(dir, "set.db") + "\"\ntable users\n\tid int @id\nend\n", []string{}, exitOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeSchema(t, t.TempDir(), "schema.sqmi", test.schema)
+
+			code := runPush(append(test.args, path))
+			if code != test.code {
+				t.Errorf("exit code %d, want %d", code, test.code)
+			}
+		})
+	}
+}
+
+// sql files are applied in the order of their names, a failing one is rolled
+// back and the ones before it stay applied
+func TestMigrateApplySQLFiles(t *testing.T) {
+	dir := t.TempDir()
+	url := filepath.Join(dir, "app.db")
+	migrations := filepath.Join(dir, "migrations")
+	writeSchema(t, migrations, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);\n")
+	writeSchema(t, migrations, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY, author INTEGER REFERENCES users (id));\n")
+	writeSchema(t, migrations, "notes.txt", "not a migration")
+
+	steps := []struct {
+		name   string
+		file   string
+		sql    string
+		code   int
+		output string
+		tables []string
+	}{
+		{"applies the files", "", "", exitOK, "Applied 001_users\nApplied 002_posts\n", []string{"users", "posts"}},
+		{"nothing to apply", "", "", exitOK, "The database is up to date\n", []string{"users", "posts"}},
+		{"failing file", "003_tags.sql", "CREATE TABLE tags (id INTEGER);\nINSERT INTO missing VALUES (1);\n", exitSchemaError, "", []string{"users", "posts"}},
+	}
+
+	for _, step := range steps {
+		if len(step.file) > 0 {
+			writeSchema(t, migrations, step.file, step.sql)
+		}
+
+		code := exitOK
+		output := withStdio(t, "", func() {
+			code = Run([]string{"migrate", "apply", "--url", url, migrations})
+		})
+		if code != step.code || output != step.output {
+			t.Errorf("%s: exit code %d with %q, want %d with %q", step.name, code, output, step.code, step.output)
+		}
+
+		db, err := openDatabase(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables, err := existingTables(db)
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the history table is there as well
+		if len(tables) != len(step.tables)+1 {
+			t.Errorf("%s: tables %v, want %v", step.name, tables, step.tables)
+		}
+		for _, table := range step.tables {
+			if !tables[table] {
+				t.Errorf("%s: table %s is missing", step.name, table)
+			}
+		}
+	}
+}
+
+func TestParseGenerateArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		inputs    []string
+		output    string
+		providers []string
+		error     string
+	}{
+		{"flags first", []string{"-o", "out.sql", "schema.sqmi"}, []string{"schema.sqmi"}, "out.sql", nil, ""},
+		{"flags last", []string{"schema.sqmi", "-o", "out.sql"}, []string{"schema.sqmi"}, "out.sql", nil, ""},
+		{
+			"flags around the schema",
+			[]string{"--provider", "sqlite,mysql", "schema.sqmi", "-o", "out.sql"},
+			[]string{"schema.sqmi"}, "out.sql", []string{"sqlite", "mysql"}, "",
+		},
+		{"stdin", []string{"-", "-o", "-"}, []string{"-"}, "-", nil, ""},
+		{"after --", []string{"-o", "out.sql", "--", "-schema.sqmi"}, []string{"-schema.sqmi"}, "out.sql", nil, ""},
+		{"two schemas", []string{"a.sqmi", "-o", "out.sql", "b.sqmi"}, nil, "", nil, "Please provide one schema file"},
+		{"unknown flag", []string{"schema.sqmi", "--out", "x"}, nil, "", nil, "flag provided but not defined"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := ParseGenerateArgs(test.args)
+			if len(test.error) != 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Fatalf("error %v, want one containing %q", err, test.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(cfg.InputFilePaths, test.inputs) {
+				t.Errorf("inputs %q, want %q", cfg.InputFilePaths, test.inputs)
+			}
+			if cfg.OutputFilePath != test.output {
+				t.Errorf("output %q, want %q", cfg.OutputFilePath, test.output)
+			}
+			if len(test.providers) != 0 && !reflect.DeepEqual(cfg.Providers, test.providers) {
+				t.Errorf("providers %q, want %q", cfg.Providers, test.providers)
+			}
+		})
+	}
+}
+
+func TestOutputFilePathFor(t *testing.T) {
+	tests := []struct {
+		output    string
+		providers []string
+		outputs   map[string]string
+		want      string
+	}{
+		{"schema.sql", []string{"sqlite"}, nil, "schema.sql"},
+		{"schema.sql", []string{"sqlite", "postgresql"}, nil, "schema.postgresql.sql"},
+		{"build/out.v1.sql", []string{"sqlite", "postgresql"}, nil, "build/out.v1.postgresql.sql"},
+		{"build.d/schema", []string{"sqlite", "postgresql"}, nil, "build.d/schema.postgresql"},
+		{".sql", []string{"sqlite", "postgresql"}, nil, ".sql.postgresql"},
+		{"schema.sql", []string{"sqlite", "postgresql"}, map[string]string{"postgresql": "pg.sql"}, "pg.sql"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			cfg := &Config{OutputFilePath: test.output, Providers: test.providers, Outputs: test.outputs}
+			if got := outputFilePathFor(cfg, "postgresql"); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSeveralProviders(t *testing.T) {
+	dir := t.TempDir()
+	schema := writeSchema(t, dir, "schema.sqmi", "set provider sqlite\n\ntable users\n\tid int @id @auto_increment\nend\n")
+	output := filepath.Join(dir, "schema.sql")
+
+	code := runGenerate([]string{"--provider", "sqlite, postgresql,mysql", "-o", output, schema})
+	if code != exitOK {
+		t.Fatalf("exit code %d", code)
+	}
+
+	wants := map[string]string{
+		"schema.sqlite.sql":     "id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL",
+		"schema.postgresql.sql": "id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY NOT NULL",
+		"schema.mysql.sql":      "id INT PRIMARY KEY AUTO_INCREMENT NOT NULL",
+	}
+	for name, want := range wants {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s does not contain %q:\n%s", name, want, content)
+		}
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("%s was written for several providers", output)
+	}
+}
+
+func TestGenerateProviderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		error string
+	}{
+		{"unknown provider", []string{"--provider", "sqlite,oracle", "schema.sqmi"}, "Provider 'oracle' not supported"},
+		{"several providers to stdout", []string{"--provider", "sqlite,mysql", "-o", "-", "schema.sqmi"}, "can not be written to stdout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseGenerateArgs(test.args)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeSchema(t, dir, "valid.sqmi", "table users\n\tid int @id\nend\n")
+	invalid := writeSchema(t, dir, "invalid.sqmi", "table users\n\tid int @default(\"one\")\nend\n")
+	output := filepath.Join(dir, "out.sql")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", []string{}, exitUsageError},
+		{"unknown command", []string{"bogus"}, exitUsageError},
+		{"help", []string{"help"}, exitOK},
+		{"help of a command", []string{"help", "generate"}, exitOK},
+		{"--help of a command", []string{"generate", "--help"}, exitOK},
+		{"version", []string{"--version"}, exitOK},
+		{"generate", []string{"generate", "-o", output, valid}, exitOK},
+		{"generate an invalid schema", []string{"generate", "-o", output, invalid}, exitSchemaError},
+		{"generate a missing file", []string{"generate", "-o", output, filepath.Join(dir, "missing.sqmi")}, exitSchemaError},
+		{"generate without a schema", []string{"generate"}, exitUsageError},
+		{"unknown flag", []string{"generate", "--bogus", valid}, exitUsageError},
+		{"legacy form", []string{"-o", output, valid}, exitOK},
+		{"validate", []string{"validate", valid}, exitOK},
+		{"validate an invalid schema", []string{"validate", invalid}, exitSchemaError},
+		{"fmt -w on stdin", []string{"fmt", "-w", "-"}, exitUsageError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := Run(test.args); code != test.code {
+				t.Errorf("exit code %d, want %d", code, test.code)
+			}
+		})
+	}
+}
+
+func TestRunWritesOutput(t *testing.T) {
+	dir := t.TempDir()
+	schema := writeSchema(t, dir, "schema.sqmi", "table users\n\tid int @id\nend\n")
+	output := filepath.Join(dir, "nested", "out.sql")
+
+	for _, args := range [][]string{{"generate", "-o", output, schema}, {"-o", output, schema}} {
+		os.Remove(output)
+		if code := Run(args); code != exitOK {
+			t.Fatalf("%q: exit code %d", args, code)
+		}
+
+		content, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		if !strings.HasPrefix(string(content), "CREATE TABLE users (") {
+			t.Errorf("%q wrote:\n%s", args, content)
+		}
+	}
+}
+
+// withStdio runs fn with input on stdin and gives what it wrote to stdout,
+// both are files so stdout is not a terminal
+func withStdio(t *testing.T, input string, fn func()) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	stdin, err := os.Create(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	stdin.WriteString(input)
+	stdin.Seek(0, 0)
+
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+
+	fn()
+
+	output, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestStdinStdout(t *testing.T) {
+	dir := t.TempDir()
+	schema := "table users\n\tid   int @id\n\tname string\nend\n"
+	path := writeSchema(t, dir, "schema.sqmi", schema)
+	sql := "CREATE TABLE users (\n\tid INTEGER PRIMARY KEY NOT NULL,\n\tname TEXT NOT NULL\n);\n\n"
+
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+	}{
+		{"schema from stdin", []string{"generate", "-o", "-", "-"}, schema, sql},
+		{"stdout when it is not a terminal", []string{"generate", path}, "", sql},
+		{"legacy form", []string{path}, "", sql},
+		{"fmt from stdin", []string{"fmt", "-"}, "table users\n  id int @id\n  name string\nend", "table users\n\tid   int    @id\n\tname string\nend\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := 0
+			output := withStdio(t, test.input, func() { code = Run(test.args) })
+			if code != exitOK {
+				t.Fatalf("exit code %d", code)
+			}
+			if output != test.output {
+				t.Errorf("output:\n%s\nwant:\n%s", output, test.output)
+			}
+		})
+	}
+}
+
+// the generated sql is piped into database clients, so it has to run as is
+func TestGeneratedSQLRuns(t *testing.T) {
+	schema := parseSchema(t, "sqlite", `
+table users
+	id int @id @auto_increment
+	email string @unique
+	bio string @nullable
+end
+
+table posts
+	id int @id @auto_increment
+	author int @reference("users", "id") @onDelete("CASCADE")
+end
+`)
+	sql, err := GenerateSQL(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openDatabase(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = applyStatements(db, splitStatements(sql))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, sql)
+	}
+}
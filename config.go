@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// names of the project configuration file, looked up in the working
+// directory and then in its parents
+var projectConfigNames = []string{"sqlmi.toml", "sqlmi.yaml", "sqlmi.yml"}
+
+type ProjectConfig struct {
+	Input      []string          `toml:"input" yaml:"input"`
+	Output     map[string]string `toml:"output" yaml:"output"`
+	Naming     NamingConfig      `toml:"naming" yaml:"naming"`
+	Lint       map[string]string `toml:"lint" yaml:"lint"`
+	Migrations string            `toml:"migrations" yaml:"migrations"`
+
+	// the file the configuration was read from, empty when there is none
+	Path string `toml:"-" yaml:"-"`
+}
+
+type NamingConfig struct {
+	Tables  string `toml:"tables" yaml:"tables"`
+	Colmuns string `toml:"columns" yaml:"columns"`
+}
+
+// LoadProjectConfig reads the configuration at path, or discovers it from the
+// working directory when path is empty. Not finding one is not an error, an
+// empty configuration is returned instead
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	if len(path) == 0 {
+		path = findProjectConfig()
+		if len(path) == 0 {
+			return &ProjectConfig{}, nil
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config: %s", err)
+	}
+
+	project := &ProjectConfig{}
+	if filepath.Ext(path) == ".toml" {
+		err = toml.Unmarshal(content, project)
+	} else {
+		err = yaml.Unmarshal(content, project)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error in config '%s': %v", path, err)
+	}
+
+	project.Path = path
+
+	err = project.validate()
+	if err != nil {
+		return nil, fmt.Errorf("Error in config '%s': %v", path, err)
+	}
+
+	project.resolvePaths()
+	return project, nil
+}
+
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		for _, name := range projectConfigNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func (p *ProjectConfig) validate() error {
+	for provider := range p.Output {
+		if !isProviderAvailable(provider) {
+			return fmt.Errorf("provider '%s' not supported in [output]", provider)
+		}
+	}
+
+	for _, convention := range []string{p.Naming.Tables, p.Naming.Colmuns} {
+		if len(convention) == 0 {
+			continue
+		}
+		if _, exists := namingConventions[convention]; !exists {
+			return fmt.Errorf("unknown naming convention '%s'", convention)
+		}
+	}
+
+	for rule, severity := range p.Lint {
+		if _, exists := lintRules[rule]; !exists {
+			return fmt.Errorf("unknown lint rule '%s'", rule)
+		}
+		if severity != "off" && severity != "warning" && severity != "error" {
+			return fmt.Errorf("lint rule '%s' must be off, warning or error", rule)
+		}
+	}
+
+	return nil
+}
+
+// paths in the configuration are relative to the file itself
+func (p *ProjectConfig) resolvePaths() {
+	dir := filepath.Dir(p.Path)
+	resolve := func(path string) string {
+		if len(path) == 0 || path == "-" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	for i, input := range p.Input {
+		p.Input[i] = resolve(input)
+	}
+
+	for provider, output := range p.Output {
+		p.Output[provider] = resolve(output)
+	}
+
+	p.Migrations = resolve(p.Migrations)
+}
+
+// OutputProviders lists the providers with an output path, in the order of
+// the providers list
+func (p *ProjectConfig) OutputProviders() []string {
+	names := []string{}
+	for _, provider := range providers {
+		if _, exists := p.Output[string(provider)]; exists {
+			names = append(names, string(provider))
+		}
+	}
+	return names
+}
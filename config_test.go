@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadProjectConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			"toml",
+			"sqlmi.toml",
+			"input = [\"schema/main.sqmi\"]\nmigrations = \"migrations\"\n\n[output]\nsqlite = \"build/schema.sql\"\n\n[naming]\ntables = \"snake_case\"\ncolumns = \"camelCase\"\n\n[lint]\nnaming = \"warning\"\n",
+		},
+		{
+			"yaml",
+			"sqlmi.yaml",
+			"input: [schema/main.sqmi]\nmigrations: migrations\noutput:\n  sqlite: build/schema.sql\nnaming:\n  tables: snake_case\n  columns: camelCase\nlint:\n  naming: warning\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeSchema(t, dir, test.file, test.content)
+
+			project, err := LoadProjectConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := &ProjectConfig{
+				Input:      []string{filepath.Join(dir, "schema/main.sqmi")},
+				Output:     map[string]string{"sqlite": filepath.Join(dir, "build/schema.sql")},
+				Naming:     NamingConfig{Tables: "snake_case", Colmuns: "camelCase"},
+				Lint:       map[string]string{"naming": "warning"},
+				Migrations: filepath.Join(dir, "migrations"),
+				Path:       path,
+			}
+			if !reflect.DeepEqual(project, want) {
+				t.Errorf("config %+v, want %+v", project, want)
+			}
+		})
+	}
+}
+
+func TestLoadProjectConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		error   string
+	}{
+		{"unknown provider", "[output]\noracle = \"schema.sql\"\n", "provider 'oracle' not supported in [output]"},
+		{"unknown naming convention", "[naming]\ntables = \"kebab-case\"\n", "unknown naming convention 'kebab-case'"},
+		{"unknown lint rule", "[lint]\nspelling = \"warning\"\n", "unknown lint rule 'spelling'"},
+		{"unknown severity", "[lint]\nnaming = \"fatal\"\n", "lint rule 'naming' must be off, warning or error"},
+		{"malformed file", "input = [\n", "Error in config"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeSchema(t, t.TempDir(), "sqlmi.toml", test.content)
+
+			_, err := LoadProjectConfig(path)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+// the configuration is looked up in the working directory and its parents
+func TestFindProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSchema(t, dir, "sqlmi.yml", "migrations: db\n")
+	nested := filepath.Join(dir, "schema", "models")
+	err := os.MkdirAll(nested, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, cwd := range []string{dir, nested} {
+		err = os.Chdir(cwd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		project, err := LoadProjectConfig("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if project.Path != path || project.Migrations != filepath.Join(dir, "db") {
+			t.Errorf("from %s: config %s with migrations %s, want %s", cwd, project.Path, project.Migrations, path)
+		}
+	}
+}
+
+func TestGenerateFromProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "schema/main.sqmi", "set provider sqlite\ntable users\n\tid int @id\nend\n")
+	config := writeSchema(t, dir, "sqlmi.toml", "input = [\"schema/main.sqmi\"]\n\n[output]\nsqlite = \"build/schema.sqlite.sql\"\npostgresql = \"build/schema.postgresql.sql\"\n")
+
+	code := Run([]string{"generate", "--config", config})
+	if code != exitOK {
+		t.Fatalf("exit code %d, want %d", code, exitOK)
+	}
+
+	for _, name := range []string{"schema.sqlite.sql", "schema.postgresql.sql"} {
+		sql, err := os.ReadFile(filepath.Join(dir, "build", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(sql), "CREATE TABLE users") {
+			t.Errorf("%s does not create the table:\n%s", name, sql)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	schema := "table UserAccounts\n\tid int @id\n\tfirst_name string\nend\ntable tags\n\tname string\nend\n"
+
+	tests := []struct {
+		name    string
+		project *ProjectConfig
+		issues  []string
+	}{
+		{
+			"rules off",
+			&ProjectConfig{},
+			[]string{},
+		},
+		{
+			"naming",
+			&ProjectConfig{Naming: NamingConfig{Tables: "snake_case", Colmuns: "camelCase"}, Lint: map[string]string{"naming": "warning"}},
+			[]string{
+				"warning naming: Table name 'UserAccounts' is not snake_case",
+				"warning naming: Colmun name 'first_name' is not camelCase",
+			},
+		},
+		{
+			"missing primary key",
+			&ProjectConfig{Lint: map[string]string{"missing-primary-key": "error"}},
+			[]string{"error missing-primary-key: Table 'tags' has no @id colmun"},
+		},
+		{
+			"rule turned off",
+			&ProjectConfig{Naming: NamingConfig{Tables: "snake_case"}, Lint: map[string]string{"naming": "off"}},
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.project.Migrations = t.TempDir()
+
+			issues := []string{}
+			for _, issue := range Lint(parseSchema(t, "sqlite", schema), test.project) {
+				issues = append(issues, issue.Severity+" "+issue.Code+": "+issue.Message)
+			}
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("issues %q, want %q", issues, test.issues)
+			}
+		})
+	}
+}
+
+// a @renamedFrom is reported once a migration has applied it
+func TestLintRenamedFrom(t *testing.T) {
+	users := "table users\n\tid int @id\n\tname string\nend\n"
+	renamed := "table people @renamedFrom(\"users\")\n\tid int @id\n\tfull_name string @renamedFrom(\"name\")\nend\n"
+
+	tests := []struct {
+		name     string
+		migrated []string
+		lint     map[string]string
+		issues   []string
+	}{
+		{
+			"no migrations",
+			[]string{},
+			nil,
+			[]string{},
+		},
+		{
+			"not applied yet",
+			[]string{users},
+			nil,
+			[]string{},
+		},
+		{
+			"applied",
+			[]string{users, renamed},
+			nil,
+			[]string{
+				"warning renamed-from 2:14: @renamedFrom(\"users\") was applied by the last migration, remove it",
+				"warning renamed-from 4:19: @renamedFrom(\"name\") was applied by the last migration, remove it",
+			},
+		},
+		{
+			"rule turned off",
+			[]string{users, renamed},
+			map[string]string{"renamed-from": "off"},
+			[]string{},
+		},
+		{
+			"rule as an error",
+			[]string{users, renamed},
+			map[string]string{"renamed-from": "error"},
+			[]string{
+				"error renamed-from 2:14: @renamedFrom(\"users\") was applied by the last migration, remove it",
+				"error renamed-from 4:19: @renamedFrom(\"name\") was applied by the last migration, remove it",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, schema := range test.migrated {
+				_, err := CreateMigration(dir, "step", parseSchema(t, "sqlite", schema), now.Add(time.Duration(i)*time.Minute))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			issues := []string{}
+			project := &ProjectConfig{Migrations: dir, Lint: test.lint}
+			for _, issue := range Lint(parseSchema(t, "sqlite", renamed), project) {
+				issues = append(issues, fmt.Sprintf("%s %s %d:%d: %s", issue.Severity, issue.Code, issue.Pos.Line, issue.Pos.Col, issue.Message))
+			}
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("issues %q, want %q", issues, test.issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// openDatabase connects to the database at url. Only sqlite is supported, as
+// file:app.db, sqlite:app.db, sqlite://app.db or a plain path
+func openDatabase(url string) (*sql.DB, error) {
+	dsn := url
+	switch {
+	case strings.HasPrefix(url, "sqlite://"):
+		dsn = strings.TrimPrefix(url, "sqlite://")
+	case strings.HasPrefix(url, "sqlite:"):
+		dsn = strings.TrimPrefix(url, "sqlite:")
+	case strings.Contains(url, "://"):
+		return nil, fmt.Errorf("Error: Database url '%s' is not supported, only sqlite databases can be used", url)
+	}
+
+	if len(dsn) == 0 {
+		return nil, fmt.Errorf("Error: Database url '%s' has no path", url)
+	}
+
+	// sqlite does not check foreign keys unless asked to
+	if strings.Contains(dsn, "?") {
+		dsn += "&_pragma=foreign_keys(1)"
+	} else {
+		dsn += "?_pragma=foreign_keys(1)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not open '%s': %v", url, err)
+	}
+	// pragmas hold for one connection, sqlite only has one writer anyway
+	db.SetMaxOpenConns(1)
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Error: Could not open '%s': %v", url, err)
+	}
+
+	return db, nil
+}
+
+// existingTables lists the tables of a sqlite database, without the ones
+// sqlite keeps for itself
+func existingTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not read the tables: %v", err)
+	}
+	defer rows.Close()
+
+	tables := map[string]bool{}
+	for rows.Next() {
+		name := ""
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Could not read the tables: %v", err)
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+// applyStatements runs the statements in one transaction, when one of them
+// fails nothing is applied
+func applyStatements(db *sql.DB, statements []string) error {
+	return inTransaction(db, func(tx *sql.Tx) error {
+		return execStatements(tx, statements)
+	})
+}
+
+// inTransaction runs fn in a transaction. Foreign keys are only checked
+// before committing, as sqlite recommends when tables are rebuilt
+func inTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	_, err := db.Exec("PRAGMA foreign_keys = OFF")
+	if err != nil {
+		return fmt.Errorf("Error: Could not disable foreign keys: %v", err)
+	}
+	defer db.Exec("PRAGMA foreign_keys = ON")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error: Could not start a transaction: %v", err)
+	}
+
+	err = fn(tx)
+	if err == nil {
+		err = checkForeignKeys(tx)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Error: Could not commit: %v", err)
+	}
+	return nil
+}
+
+func execStatements(tx *sql.Tx, statements []string) error {
+	for _, statement := range statements {
+		_, err := tx.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("Error: %v\nin statement:\n%s", err, statement)
+		}
+	}
+	return nil
+}
+
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return fmt.Errorf("Error: Could not check foreign keys: %v", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		table, rowid, parent := "", sql.NullInt64{}, ""
+		fkid := 0
+		err = rows.Scan(&table, &rowid, &parent, &fkid)
+		if err != nil {
+			return fmt.Errorf("Error: Could not check foreign keys: %v", err)
+		}
+		return fmt.Errorf("Error: Row %d of table '%s' references a missing row of '%s'", rowid.Int64, table, parent)
+	}
+	return rows.Err()
+}
+
+// splitStatements splits sql on the semicolons that are not in a string, a
+// quoted name or a comment. Statements keep their semicolon
+func splitStatements(content string) []string {
+	statements := []string{}
+	start := 0
+
+	for i := 0; i < len(content); i++ {
+		switch ch := content[i]; {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := strings.IndexByte(content[i+1:], ch)
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end + 1
+			}
+		case strings.HasPrefix(content[i:], "--"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end
+			}
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end + 3
+			}
+		case ch == ';':
+			statements = appendStatement(statements, content[start:i+1])
+			start = i + 1
+		}
+	}
+
+	if start < len(content) {
+		statements = appendStatement(statements, content[start:])
+	}
+	return statements
+}
+
+// appendStatement skips what is only whitespace and comments
+func appendStatement(statements []string, statement string) []string {
+	statement = strings.TrimSpace(statement)
+
+	code := statement
+	for len(code) > 0 {
+		if strings.HasPrefix(code, "--") {
+			_, code, _ = strings.Cut(code, "\n")
+		} else if strings.HasPrefix(code, "/*") {
+			_, code, _ = strings.Cut(code, "*/")
+		} else {
+			break
+		}
+		code = strings.TrimSpace(code)
+	}
+
+	if len(code) == 0 || code == ";" {
+		return statements
+	}
+	return append(statements, statement)
+}
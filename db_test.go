@@ -0,0 +1,171 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			"statements",
+			"CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\n",
+			[]string{"CREATE TABLE a (id INTEGER);", "CREATE TABLE b (id INTEGER);"},
+		},
+		{
+			"last without semicolon",
+			"DROP TABLE a;\nDROP TABLE b",
+			[]string{"DROP TABLE a;", "DROP TABLE b"},
+		},
+		{
+			"semicolon in a string",
+			"INSERT INTO a VALUES ('x;y');\nDROP TABLE b;",
+			[]string{"INSERT INTO a VALUES ('x;y');", "DROP TABLE b;"},
+		},
+		{
+			"escaped quote in a string",
+			"INSERT INTO a VALUES ('it''s; here');\nDROP TABLE b;",
+			[]string{"INSERT INTO a VALUES ('it''s; here');", "DROP TABLE b;"},
+		},
+		{
+			"semicolon in quoted names",
+			"CREATE TABLE \"a;b\" (`c;d` INTEGER);",
+			[]string{"CREATE TABLE \"a;b\" (`c;d` INTEGER);"},
+		},
+		{
+			"semicolon in a line comment",
+			"-- first; second\nDROP TABLE a;",
+			[]string{"-- first; second\nDROP TABLE a;"},
+		},
+		{
+			"semicolon in a block comment",
+			"/* a; b */ DROP TABLE a;",
+			[]string{"/* a; b */ DROP TABLE a;"},
+		},
+		{
+			"only comments and empty statements",
+			"-- TODO users.id: change the primary key by hand\n;\n  ;",
+			[]string{},
+		},
+		{
+			"unterminated string",
+			"INSERT INTO a VALUES ('x;",
+			[]string{"INSERT INTO a VALUES ('x;"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitStatements(test.content)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", test.content, got, test.want)
+			}
+		})
+	}
+}
+
+func TestOpenDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		url   string
+		error string
+	}{
+		{filepath.Join(dir, "plain.db"), ""},
+		{"file:" + filepath.Join(dir, "file.db"), ""},
+		{"sqlite:" + filepath.Join(dir, "scheme.db"), ""},
+		{"sqlite://" + filepath.Join(dir, "slashes.db"), ""},
+		{"file:" + filepath.Join(dir, "query.db") + "?mode=rwc", ""},
+		{"postgres://localhost/app", "only sqlite databases can be used"},
+		{"sqlite://", "has no path"},
+		{filepath.Join(dir, "missing", "app.db"), "Could not open"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			db, err := openDatabase(test.url)
+			if len(test.error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Errorf("error %v, want one containing %q", err, test.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			// foreign keys are checked on every connection
+			enabled := 0
+			err = db.QueryRow("PRAGMA foreign_keys").Scan(&enabled)
+			if err != nil || enabled != 1 {
+				t.Errorf("foreign keys %d: %v, want them on", enabled, err)
+			}
+		})
+	}
+}
+
+// a failing statement or a broken foreign key applies nothing
+func TestApplyStatements(t *testing.T) {
+	tests := []struct {
+		name       string
+		statements []string
+		error      string
+		tables     int
+	}{
+		{
+			"applied",
+			[]string{"CREATE TABLE a (id INTEGER PRIMARY KEY);", "CREATE TABLE b (id INTEGER PRIMARY KEY);"},
+			"",
+			2,
+		},
+		{
+			"failing statement",
+			[]string{"CREATE TABLE a (id INTEGER PRIMARY KEY);", "CREATE TABLE a (id INTEGER);"},
+			"in statement:\nCREATE TABLE a (id INTEGER);",
+			0,
+		},
+		{
+			"missing referenced row",
+			[]string{
+				"CREATE TABLE a (id INTEGER PRIMARY KEY);",
+				"CREATE TABLE b (a_id INTEGER REFERENCES a (id));",
+				"INSERT INTO b VALUES (1);",
+			},
+			"Row 1 of table 'b' references a missing row of 'a'",
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := openDatabase(filepath.Join(t.TempDir(), "app.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			err = applyStatements(db, test.statements)
+			if len(test.error) == 0 && err != nil {
+				t.Fatal(err)
+			}
+			if len(test.error) > 0 && (err == nil || !strings.Contains(err.Error(), test.error)) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+
+			tables, err := existingTables(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tables) != test.tables {
+				t.Errorf("tables %v, want %d", tables, test.tables)
+			}
+		})
+	}
+}
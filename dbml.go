@@ -0,0 +1,934 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dbml types and the logical type they become, types not listed here are
+// kept as raw types
+var dbmlTypes = map[string]string{
+	"int":               "int",
+	"integer":           "int",
+	"int4":              "int",
+	"serial":            "int",
+	"bigint":            "bigint",
+	"int8":              "bigint",
+	"bigserial":         "bigint",
+	"smallint":          "smallint",
+	"int2":              "smallint",
+	"varchar":           "string",
+	"character varying": "string",
+	"string":            "string",
+	"char":              "char",
+	"character":         "char",
+	"text":              "text",
+	"bool":              "bool",
+	"boolean":           "bool",
+	"timestamp":         "datetime",
+	"datetime":          "datetime",
+	"timestamptz":       "timestamptz",
+	"date":              "date",
+	"time":              "time",
+	"float":             "float",
+	"double":            "float",
+	"double precision":  "float",
+	"real":              "float",
+	"decimal":           "decimal",
+	"numeric":           "decimal",
+	"uuid":              "uuid",
+	"json":              "json",
+	"jsonb":             "json",
+	"blob":              "blob",
+	"bytea":             "blob",
+}
+
+var dbmlProviders = map[string]Provider{
+	"sqlite":     sqlite,
+	"postgresql": postgresql,
+	"postgres":   postgresql,
+	"mysql":      mysql,
+}
+
+type dbmlToken struct {
+	Kind  string // iden, string, expr, number, op, newline, eof
+	Value string
+	Line  int
+}
+
+type dbmlParser struct {
+	tokens   []*dbmlToken
+	pos      int
+	ast      *AST
+	enums    map[string]bool
+	aliases  map[string]string
+	refs     []*dbmlRef
+	warnings []string
+}
+
+// a relationship, the source colmun references the target colmun
+type dbmlRef struct {
+	SourceTable string
+	SourceCol   string
+	TargetTable string
+	TargetCol   string
+	OnDelete    string
+	OnUpdate    string
+	Line        int
+}
+
+// ImportDBML converts a dbml schema, what sql-mi can not express (enums,
+// composite indexes, many to many relationships) is left out and reported in
+// the warnings
+func ImportDBML(content string) (*AST, []string, error) {
+	initValues()
+
+	tokens, err := tokenizeDBML(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &dbmlParser{
+		tokens: tokens,
+		ast: &AST{
+			map[string]string{"provider": "sqlite"},
+			[]*TabelAST{},
+			[]string{},
+			[]*SettingAST{},
+			[]*ImportAST{},
+		},
+		enums:    map[string]bool{},
+		aliases:  map[string]string{},
+		refs:     []*dbmlRef{},
+		warnings: []string{},
+	}
+
+	// enums are needed to know the type of colmuns, whatever the order
+	for i, tok := range tokens {
+		if tok.Kind == "iden" && strings.EqualFold(tok.Value, "enum") && i+1 < len(tokens) {
+			p.enums[lastNamePart(tokens[i+1].Value)] = true
+		}
+	}
+
+	err = p.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = p.applyRefs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sorted, warnings := sortTablesByReferences(p.ast)
+	p.ast.Tables = sorted
+	return p.ast, append(p.warnings, warnings...), nil
+}
+
+func (p *dbmlParser) parse() error {
+	for {
+		tok := p.next()
+		switch {
+		case tok.Kind == "eof":
+			return nil
+		case tok.Kind == "newline":
+			continue
+		case tok.Kind != "iden":
+			return p.errorf(tok, "Unexpected '%s'", tok.Value)
+		}
+
+		var err error
+		switch strings.ToLower(tok.Value) {
+		case "table":
+			err = p.parseTable()
+		case "ref":
+			err = p.parseRef()
+		case "enum":
+			name := p.next()
+			p.warn(name, "Enum '%s' is not supported, its colmuns become strings", name.Value)
+			err = p.skipBlock()
+		case "project":
+			err = p.parseProject()
+		default:
+			// table groups, sticky notes and other blocks only matter to
+			// dbdiagram.io
+			err = p.skipBlock()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *dbmlParser) parseTable() error {
+	nameTok := p.next()
+	name := lastNamePart(nameTok.Value)
+	if !isValidTableName(name) {
+		return p.errorf(nameTok, "Invalid table name '%s'", nameTok.Value)
+	}
+	if findTable(p.ast, name) != nil {
+		return p.errorf(nameTok, "Table with name '%s' already declared", name)
+	}
+
+	// refs can use the alias instead of the table name
+	if p.peek().Kind == "iden" && strings.EqualFold(p.peek().Value, "as") {
+		p.next()
+		aliasTok := p.next()
+		if _, exists := p.aliases[aliasTok.Value]; exists {
+			return p.errorf(aliasTok, "Alias '%s' already declared", aliasTok.Value)
+		}
+		p.aliases[aliasTok.Value] = name
+	}
+
+	table := &TabelAST{name, []*ColmunAST{}, []*ReferenceAST{}, &AttributesAST{}, Position{}, ""}
+
+	if p.peek().Value == "[" {
+		for _, setting := range p.parseSettings() {
+			if setting.Key == "note" {
+				table.Doc = setting.Value
+			}
+		}
+	}
+
+	err := p.expect("{")
+	if err != nil {
+		return err
+	}
+
+	for {
+		tok := p.next()
+		switch {
+		case tok.Kind == "eof":
+			return p.errorf(tok, "Missing '}' at the end of table '%s'", name)
+		case tok.Kind == "newline":
+			continue
+		case tok.Value == "}":
+			p.ast.Tables = append(p.ast.Tables, table)
+			return nil
+		case tok.Kind == "iden" && strings.EqualFold(tok.Value, "note") && p.peek().Value == ":":
+			p.next()
+			table.Doc = p.next().Value
+		case tok.Kind == "iden" && strings.EqualFold(tok.Value, "note") && p.peek().Value == "{":
+			p.next()
+			table.Doc = p.next().Value
+			p.skipUntil("}")
+		case tok.Kind == "iden" && strings.EqualFold(tok.Value, "indexes") && p.peek().Value == "{":
+			p.next()
+			err := p.parseIndexes(table)
+			if err != nil {
+				return err
+			}
+		default:
+			err := p.parseColmun(table, tok)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *dbmlParser) parseColmun(table *TabelAST, nameTok *dbmlToken) error {
+	if !isValidColmunName(nameTok.Value) {
+		return p.errorf(nameTok, "Invalid colmun name '%s'", nameTok.Value)
+	}
+	if findColmun(table, nameTok.Value) != nil {
+		return p.errorf(nameTok, "Colmun with name '%s' already declared", nameTok.Value)
+	}
+
+	typeTok := p.next()
+	if typeTok.Kind != "iden" && typeTok.Kind != "string" {
+		return p.errorf(typeTok, "Missing type of colmun '%s'", nameTok.Value)
+	}
+
+	typeName := typeTok.Value
+	params := []string{}
+	if p.peek().Value == "(" {
+		p.next()
+		for tok := p.next(); tok.Value != ")"; tok = p.next() {
+			if tok.Kind == "eof" || tok.Kind == "newline" {
+				return p.errorf(tok, "Missing ')' in the type of colmun '%s'", nameTok.Value)
+			}
+			if tok.Value != "," {
+				params = append(params, tok.Value)
+			}
+		}
+	}
+	for p.peek().Value == "[" && p.peekAt(1).Value == "]" {
+		p.next()
+		p.next()
+		typeName += "[]"
+	}
+
+	colmun := &ColmunAST{nameTok.Value, "", []string{}, &AttributesAST{}, Position{}, ""}
+	p.setColmunType(colmun, typeName, params)
+
+	nullable := true
+	if p.peek().Value == "[" {
+		for _, setting := range p.parseSettings() {
+			switch setting.Key {
+			case "pk", "primary key":
+				nullable = false
+				(*colmun.Attributes)["id"] = &AttributeAST{"id", []*AttributeArgAST{}, Position{}}
+			case "increment":
+				(*colmun.Attributes)["auto_increment"] = &AttributeAST{"auto_increment", []*AttributeArgAST{}, Position{}}
+			case "unique":
+				(*colmun.Attributes)["unique"] = &AttributeAST{"unique", []*AttributeArgAST{}, Position{}}
+			case "not null":
+				nullable = false
+			case "null":
+				nullable = true
+			case "note":
+				colmun.Doc = setting.Value
+			case "default":
+				if setting.Kind == "iden" && strings.EqualFold(setting.Value, "null") {
+					continue
+				}
+				argType := "string"
+				if setting.Kind == "expr" {
+					argType = "raw"
+				}
+				(*colmun.Attributes)["default"] = &AttributeAST{
+					"default",
+					[]*AttributeArgAST{{setting.Value, argType, Position{}}},
+					Position{},
+				}
+			case "ref":
+				ref, err := p.parseRefEndpoints(
+					append([]*dbmlToken{{"iden", table.Name + "." + colmun.Name, nameTok.Line}}, setting.Tokens...),
+				)
+				if err != nil {
+					return err
+				}
+				if ref != nil {
+					p.refs = append(p.refs, ref)
+				}
+			default:
+				p.warn(nameTok, "Setting '%s' of colmun '%s' is not supported, skipped", setting.Key, colmun.Name)
+			}
+		}
+	}
+
+	if nullable {
+		(*colmun.Attributes)["nullable"] = &AttributeAST{"nullable", []*AttributeArgAST{}, Position{}}
+	}
+
+	table.Colmuns = append(table.Colmuns, colmun)
+	return nil
+}
+
+func (p *dbmlParser) setColmunType(colmun *ColmunAST, typeName string, params []string) {
+	logical, exists := dbmlTypes[strings.ToLower(typeName)]
+
+	if !exists && p.enums[lastNamePart(typeName)] {
+		logical, exists = "string", true
+		params = []string{}
+	}
+
+	// parameters are only kept by types that take them
+	if exists && len(params) > 0 {
+		paramType, takesParams := paramTypes[logical]
+		if !takesParams || len(params) < paramType.Min || len(params) > paramType.Max {
+			exists = false
+		}
+	}
+
+	if !exists {
+		raw := typeName
+		if len(params) > 0 {
+			raw += "(" + strings.Join(params, ",") + ")"
+		}
+		colmun.Data_type = "raw"
+		(*colmun.Attributes)["raw"] = &AttributeAST{"raw", []*AttributeArgAST{{raw, "string", Position{}}}, Position{}}
+		return
+	}
+
+	colmun.Data_type = logical
+	colmun.Type_params = params
+}
+
+// indexes on one colmun marked unique become @unique, other indexes can not
+// be expressed
+func (p *dbmlParser) parseIndexes(table *TabelAST) error {
+	for {
+		tok := p.next()
+		switch {
+		case tok.Kind == "eof":
+			return p.errorf(tok, "Missing '}' at the end of the indexes of '%s'", table.Name)
+		case tok.Kind == "newline":
+			continue
+		case tok.Value == "}":
+			return nil
+		}
+
+		colmuns := []string{tok.Value}
+		if tok.Value == "(" {
+			colmuns = []string{}
+			for inner := p.next(); inner.Value != ")"; inner = p.next() {
+				if inner.Kind == "eof" {
+					return p.errorf(inner, "Missing ')' in an index of '%s'", table.Name)
+				}
+				if inner.Value != "," {
+					colmuns = append(colmuns, inner.Value)
+				}
+			}
+		}
+
+		unique := false
+		if p.peek().Value == "[" {
+			for _, setting := range p.parseSettings() {
+				if setting.Key == "unique" || setting.Key == "pk" {
+					unique = true
+				}
+			}
+		}
+
+		colmun := findColmun(table, colmuns[0])
+		if len(colmuns) == 1 && unique && colmun != nil && tok.Kind == "iden" {
+			(*colmun.Attributes)["unique"] = &AttributeAST{"unique", []*AttributeArgAST{}, Position{}}
+			continue
+		}
+
+		p.warn(tok, "Index on (%s) of table '%s' is not supported, skipped", strings.Join(colmuns, ", "), table.Name)
+	}
+}
+
+func (p *dbmlParser) parseRef() error {
+	tok := p.next()
+
+	// the name of a ref is optional
+	if tok.Kind == "iden" {
+		tok = p.next()
+	}
+
+	var endpoints []*dbmlToken
+	switch tok.Value {
+	case ":":
+		for p.peek().Kind != "newline" && p.peek().Kind != "eof" {
+			endpoints = append(endpoints, p.next())
+		}
+	case "{":
+		for p.peek().Value != "}" && p.peek().Kind != "eof" {
+			next := p.next()
+			if next.Kind != "newline" {
+				endpoints = append(endpoints, next)
+			}
+		}
+		err := p.expect("}")
+		if err != nil {
+			return err
+		}
+	default:
+		return p.errorf(tok, "Expected ':' or '{' after Ref")
+	}
+
+	ref, err := p.parseRefEndpoints(endpoints)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		p.refs = append(p.refs, ref)
+	}
+	return nil
+}
+
+// parseRefEndpoints reads <table>.<colmun> <op> <table>.<colmun> [settings],
+// the settings being delete and update actions
+func (p *dbmlParser) parseRefEndpoints(tokens []*dbmlToken) (*dbmlRef, error) {
+	if len(tokens) < 3 {
+		line := 0
+		if len(tokens) > 0 {
+			line = tokens[0].Line
+		}
+		return nil, fmt.Errorf("Error: Incomplete ref at line %d", line)
+	}
+
+	left, op, right := tokens[0], tokens[1], tokens[2]
+	leftTable, leftCol, leftOk := splitColmunRef(left.Value)
+	rightTable, rightCol, rightOk := splitColmunRef(right.Value)
+	if !leftOk || !rightOk {
+		p.warn(left, "Ref between '%s' and '%s' is not supported, only single colmuns can be referenced", left.Value, right.Value)
+		return nil, nil
+	}
+
+	ref := &dbmlRef{Line: left.Line}
+	switch op.Value {
+	case ">", "-":
+		ref.SourceTable, ref.SourceCol, ref.TargetTable, ref.TargetCol = leftTable, leftCol, rightTable, rightCol
+	case "<":
+		ref.SourceTable, ref.SourceCol, ref.TargetTable, ref.TargetCol = rightTable, rightCol, leftTable, leftCol
+	case "<>":
+		p.warn(left, "Many to many ref between '%s' and '%s' is not supported, skipped", left.Value, right.Value)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Error: Unknown relationship '%s' at line %d", op.Value, op.Line)
+	}
+
+	// the settings were tokenized with the rest of the line
+	settings := tokens[3:]
+	if len(settings) > 0 && settings[0].Value == "[" {
+		sub := &dbmlParser{tokens: append(settings, &dbmlToken{"eof", "", 0})}
+		for _, setting := range sub.parseSettings() {
+			switch setting.Key {
+			case "delete":
+				ref.OnDelete = strings.ToUpper(setting.Value)
+			case "update":
+				ref.OnUpdate = strings.ToUpper(setting.Value)
+			}
+		}
+	}
+
+	return ref, nil
+}
+
+func (p *dbmlParser) applyRefs() error {
+	for _, ref := range p.refs {
+		source := findTable(p.ast, p.tableName(ref.SourceTable))
+		target := findTable(p.ast, p.tableName(ref.TargetTable))
+		if source == nil || target == nil {
+			return fmt.Errorf("Error: Ref at line %d uses an unknown table", ref.Line)
+		}
+		if findColmun(source, ref.SourceCol) == nil || findColmun(target, ref.TargetCol) == nil {
+			return fmt.Errorf("Error: Ref at line %d uses an unknown colmun", ref.Line)
+		}
+
+		if findReference(source, ref.SourceCol) != nil {
+			p.warnf("Ref at line %d: '%s.%s' already references a colmun, skipped", ref.Line, source.Name, ref.SourceCol)
+			continue
+		}
+
+		source.References = append(source.References, &ReferenceAST{
+			target.Name,
+			ref.TargetCol,
+			ref.SourceCol,
+			ref.OnDelete,
+			ref.OnUpdate,
+		})
+	}
+	return nil
+}
+
+// tableName gives the table an alias stands for, other names are kept
+func (p *dbmlParser) tableName(name string) string {
+	if table, exists := p.aliases[name]; exists {
+		return table
+	}
+	return name
+}
+
+func (p *dbmlParser) parseProject() error {
+	// Project <name> { database_type: '<name>' }
+	for tok := p.next(); tok.Value != "{"; tok = p.next() {
+		if tok.Kind == "eof" {
+			return p.errorf(tok, "Missing '{' after Project")
+		}
+	}
+
+	for depth := 1; depth > 0; {
+		tok := p.next()
+		switch {
+		case tok.Kind == "eof":
+			return p.errorf(tok, "Missing '}' at the end of Project")
+		case tok.Value == "{":
+			depth++
+		case tok.Value == "}":
+			depth--
+		case tok.Kind == "iden" && tok.Value == "database_type" && p.peek().Value == ":":
+			p.next()
+			value := p.next()
+			provider, exists := dbmlProviders[strings.ToLower(value.Value)]
+			if !exists {
+				p.warn(value, "Database type '%s' is not supported, using sqlite", value.Value)
+				continue
+			}
+			p.ast.Configuration["provider"] = string(provider)
+			p.ast.Settings = append(p.ast.Settings, &SettingAST{"provider", string(provider), Position{}})
+		}
+	}
+	return nil
+}
+
+type dbmlSetting struct {
+	Key    string
+	Value  string
+	Kind   string
+	Tokens []*dbmlToken
+}
+
+// parseSettings reads [key, key: value, ...], keys made of several words
+// like "not null" are joined with a space
+func (p *dbmlParser) parseSettings() []*dbmlSetting {
+	p.next()
+
+	settings := []*dbmlSetting{}
+	current := []*dbmlToken{}
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+
+		setting := &dbmlSetting{Tokens: []*dbmlToken{}}
+		key := []string{}
+		i := 0
+		for ; i < len(current) && current[i].Value != ":"; i++ {
+			key = append(key, strings.ToLower(current[i].Value))
+		}
+		setting.Key = strings.Join(key, " ")
+
+		if i < len(current) {
+			setting.Tokens = current[i+1:]
+			values := []string{}
+			for _, tok := range setting.Tokens {
+				values = append(values, tok.Value)
+				setting.Kind = tok.Kind
+			}
+			setting.Value = strings.Join(values, " ")
+		}
+
+		settings = append(settings, setting)
+		current = []*dbmlToken{}
+	}
+
+	depth := 0
+	for {
+		tok := p.next()
+		if tok.Kind == "eof" || (depth == 0 && tok.Value == "]") {
+			break
+		}
+		if tok.Kind == "newline" {
+			continue
+		}
+		if tok.Value == "[" {
+			depth++
+		} else if tok.Value == "]" {
+			depth--
+		}
+
+		if depth == 0 && tok.Value == "," {
+			flush()
+			continue
+		}
+		current = append(current, tok)
+	}
+	flush()
+
+	return settings
+}
+
+// skipBlock skips everything until the block that follows is closed
+func (p *dbmlParser) skipBlock() error {
+	for tok := p.next(); tok.Value != "{"; tok = p.next() {
+		if tok.Kind == "eof" {
+			return nil
+		}
+	}
+
+	for depth := 1; depth > 0; {
+		tok := p.next()
+		switch {
+		case tok.Kind == "eof":
+			return p.errorf(tok, "Missing '}'")
+		case tok.Value == "{":
+			depth++
+		case tok.Value == "}":
+			depth--
+		}
+	}
+	return nil
+}
+
+func (p *dbmlParser) skipUntil(value string) {
+	for tok := p.next(); tok.Value != value && tok.Kind != "eof"; tok = p.next() {
+	}
+}
+
+func (p *dbmlParser) next() *dbmlToken {
+	tok := p.peek()
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *dbmlParser) peek() *dbmlToken {
+	return p.peekAt(0)
+}
+
+func (p *dbmlParser) peekAt(offset int) *dbmlToken {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *dbmlParser) expect(value string) error {
+	for p.peek().Kind == "newline" {
+		p.next()
+	}
+
+	tok := p.next()
+	if tok.Value != value {
+		return p.errorf(tok, "Expected '%s' but got '%s'", value, tok.Value)
+	}
+	return nil
+}
+
+func (p *dbmlParser) errorf(tok *dbmlToken, format string, args ...interface{}) error {
+	return fmt.Errorf("Error: line %d: %s", tok.Line, fmt.Sprintf(format, args...))
+}
+
+func (p *dbmlParser) warn(tok *dbmlToken, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf("line %d: %s", tok.Line, fmt.Sprintf(format, args...)))
+}
+
+func (p *dbmlParser) warnf(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+// splitColmunRef splits [schema.]table.colmun, composite colmuns like
+// table.(a, b) are not supported
+func splitColmunRef(name string) (string, string, bool) {
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], true
+}
+
+// names can be qualified with a schema like public.users
+func lastNamePart(name string) string {
+	parts := strings.Split(name, ".")
+	return parts[len(parts)-1]
+}
+
+var dbmlNumberRegex = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?`)
+
+// tokenizeDBML splits dbml into tokens, dotted names like users.id are one
+// identifier token and comments are dropped
+func tokenizeDBML(content string) ([]*dbmlToken, error) {
+	tokens := []*dbmlToken{}
+	line := 1
+	i := 0
+
+	for i < len(content) {
+		ch := content[i]
+
+		switch {
+		case ch == '\n':
+			tokens = append(tokens, &dbmlToken{"newline", "\n", line})
+			line++
+			i++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case strings.HasPrefix(content[i:], "//"):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("Error: line %d: Unterminated comment", line)
+			}
+			line += strings.Count(content[i:i+2+end], "\n")
+			i += end + 4
+		case strings.HasPrefix(content[i:], "'''"):
+			end := strings.Index(content[i+3:], "'''")
+			if end < 0 {
+				return nil, fmt.Errorf("Error: line %d: Unterminated string", line)
+			}
+			value := content[i+3 : i+3+end]
+			tokens = append(tokens, &dbmlToken{"string", strings.TrimSpace(value), line})
+			line += strings.Count(value, "\n")
+			i += end + 6
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := i + 1
+			for end < len(content) && content[end] != ch {
+				if content[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(content) {
+				return nil, fmt.Errorf("Error: line %d: Unterminated string", line)
+			}
+			value := strings.ReplaceAll(content[i+1:end], "\\"+string(ch), string(ch))
+
+			kind := "string"
+			if ch == '`' {
+				kind = "expr"
+			} else if ch == '"' {
+				// double quotes are quoted names
+				kind = "iden"
+			}
+
+			// a quoted name can be followed by .colmun
+			if kind == "iden" && len(tokens) > 0 && strings.HasSuffix(tokens[len(tokens)-1].Value, ".") {
+				tokens[len(tokens)-1].Value += value
+			} else {
+				tokens = append(tokens, &dbmlToken{kind, value, line})
+			}
+			line += strings.Count(value, "\n")
+			i = end + 1
+		case strings.HasPrefix(content[i:], "<>"):
+			tokens = append(tokens, &dbmlToken{"op", "<>", line})
+			i += 2
+		case ch == '-' && dbmlNumberRegex.MatchString(content[i:]) && !isRelationMinus(tokens):
+			number := dbmlNumberRegex.FindString(content[i:])
+			tokens = append(tokens, &dbmlToken{"number", number, line})
+			i += len(number)
+		case strings.ContainsRune("{}[]():,<>-", rune(ch)):
+			tokens = append(tokens, &dbmlToken{"op", string(ch), line})
+			i++
+		case isNumber(ch):
+			number := dbmlNumberRegex.FindString(content[i:])
+			tokens = append(tokens, &dbmlToken{"number", number, line})
+			i += len(number)
+		case isLetter(ch):
+			start := i
+			for i < len(content) && (isLetter(content[i]) || isNumber(content[i]) || content[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, &dbmlToken{"iden", content[start:i], line})
+		default:
+			return nil, fmt.Errorf("Error: line %d: Unexpected character '%c'", line, ch)
+		}
+	}
+
+	tokens = append(tokens, &dbmlToken{"eof", "", line})
+	return tokens, nil
+}
+
+// after a colmun name a minus is a one to one relationship, not a sign
+func isRelationMinus(tokens []*dbmlToken) bool {
+	return len(tokens) > 0 && tokens[len(tokens)-1].Kind == "iden"
+}
+
+// GenerateDBML writes the schema as dbml for dbdiagram.io, types are the sql
+// types of the configured provider
+func GenerateDBML(ast *AST, cfg *Config) (string, error) {
+	initValues()
+
+	target := Provider(ast.Configuration["provider"])
+	if len(cfg.Providers) > 0 {
+		target = Provider(cfg.Providers[0])
+	}
+
+	blocks := []string{fmt.Sprintf("Project schema {\n  database_type: '%s'\n}\n", dbmlDatabaseType(target))}
+	refs := []string{}
+
+	for _, table := range ast.Tables {
+		lines := []string{fmt.Sprintf("Table %s {", table.Name)}
+
+		for _, colmun := range table.Colmuns {
+			sqlType, err := providerType(colmun, target)
+			if err != nil {
+				return "", err
+			}
+			if strings.ContainsAny(sqlType, " \"") {
+				sqlType = fmt.Sprintf("%q", strings.ToLower(sqlType))
+			} else {
+				sqlType = strings.ToLower(sqlType)
+			}
+
+			settings := dbmlColmunSettings(colmun)
+			line := fmt.Sprintf("  %s %s", colmun.Name, sqlType)
+			if len(settings) > 0 {
+				line += " [" + strings.Join(settings, ", ") + "]"
+			}
+			lines = append(lines, line)
+		}
+
+		if len(table.Doc) > 0 {
+			lines = append(lines, "", "  Note: "+dbmlString(table.Doc))
+		}
+
+		lines = append(lines, "}")
+		blocks = append(blocks, strings.Join(lines, "\n")+"\n")
+
+		for _, ref := range table.References {
+			line := fmt.Sprintf("Ref: %s.%s > %s.%s", table.Name, ref.SourceCol, ref.TargetTable, ref.TargetCol)
+
+			actions := []string{}
+			if len(ref.OnDelete) > 0 {
+				actions = append(actions, "delete: "+strings.ToLower(ref.OnDelete))
+			}
+			if len(ref.OnUpdate) > 0 {
+				actions = append(actions, "update: "+strings.ToLower(ref.OnUpdate))
+			}
+			if len(actions) > 0 {
+				line += " [" + strings.Join(actions, ", ") + "]"
+			}
+			refs = append(refs, line)
+		}
+	}
+
+	if len(refs) > 0 {
+		blocks = append(blocks, strings.Join(refs, "\n")+"\n")
+	}
+
+	return strings.Join(blocks, "\n"), nil
+}
+
+func dbmlColmunSettings(colmun *ColmunAST) []string {
+	settings := []string{}
+	attrs := *colmun.Attributes
+
+	if _, exists := attrs["id"]; exists {
+		settings = append(settings, "pk")
+	}
+	if _, exists := attrs["auto_increment"]; exists {
+		settings = append(settings, "increment")
+	}
+	if _, exists := attrs["unique"]; exists {
+		settings = append(settings, "unique")
+	}
+	_, isId := attrs["id"]
+	if _, exists := attrs["nullable"]; !exists && !isId {
+		settings = append(settings, "not null")
+	}
+
+	if attr, exists := attrs["default"]; exists && len(attr.Values) == 1 {
+		value := attr.Values[0]
+		switch {
+		case value.Type == "raw":
+			settings = append(settings, fmt.Sprintf("default: `%s`", value.Value))
+		case dbmlNumberRegex.FindString(value.Value) == value.Value && isNumericType(colmun.Data_type):
+			settings = append(settings, "default: "+value.Value)
+		case (value.Value == "true" || value.Value == "false") && (colmun.Data_type == "bool" || colmun.Data_type == "boolean"):
+			settings = append(settings, "default: "+value.Value)
+		default:
+			settings = append(settings, "default: "+dbmlString(value.Value))
+		}
+	}
+
+	if len(colmun.Doc) > 0 {
+		settings = append(settings, "note: "+dbmlString(colmun.Doc))
+	}
+
+	return settings
+}
+
+func isNumericType(dataType string) bool {
+	switch dataType {
+	case "int", "bigint", "smallint", "float", "decimal":
+		return true
+	}
+	return false
+}
+
+func dbmlString(s string) string {
+	if strings.Contains(s, "\n") {
+		return "'''\n" + s + "\n'''"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+func dbmlDatabaseType(p Provider) string {
+	switch p {
+	case postgresql:
+		return "PostgreSQL"
+	case mysql:
+		return "MySQL"
+	}
+	return "SQLite"
+}
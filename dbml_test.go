@@ -0,0 +1,344 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportDBML(t *testing.T) {
+	tests := []struct {
+		name     string
+		dbml     string
+		schema   string
+		warnings []string
+	}{
+		{
+			"colmun settings",
+			`
+Table users {
+  id integer [pk, increment]
+  email varchar(255) [unique, not null]
+  active boolean [default: true, not null]
+  created_at timestamp [default: ` + "`now()`" + `, note: 'When the row was created']
+  total money
+}
+`,
+			`table users
+	id         int         @id @auto_increment
+	email      string(255) @unique
+	active     bool        @default("true")
+	/// When the row was created
+	created_at datetime    @default(` + "`now()`" + `) @nullable
+	total      ` + "`money`" + `     @nullable
+end
+`,
+			[]string{},
+		},
+		{
+			"project database type",
+			`
+Project shop {
+  database_type: 'PostgreSQL'
+}
+
+Table users {
+  id int [pk]
+}
+`,
+			`set provider postgresql
+
+table users
+	id int @id
+end
+`,
+			[]string{},
+		},
+		{
+			"ref block with actions",
+			`
+Table posts {
+  id int [pk]
+  author int [not null]
+}
+
+Table users {
+  id int [pk]
+}
+
+Ref: posts.author > users.id [delete: cascade, update: set null]
+`,
+			`table users
+	id int @id
+end
+
+table posts
+	id     int @id
+	author int @reference("users", "id") @onDelete("CASCADE") @onUpdate("SET NULL")
+end
+`,
+			[]string{},
+		},
+		{
+			"inline ref",
+			`
+Table users {
+  id int [pk]
+}
+
+Table posts {
+  id int [pk]
+  author int [not null, ref: > users.id]
+}
+`,
+			`table users
+	id int @id
+end
+
+table posts
+	id     int @id
+	author int @reference("users", "id")
+end
+`,
+			[]string{},
+		},
+		{
+			"one to many written from the referenced side",
+			`
+Table users {
+  id int [pk]
+}
+
+Table posts {
+  id int [pk]
+  author int [not null]
+}
+
+Ref: users.id < posts.author
+`,
+			`table users
+	id int @id
+end
+
+table posts
+	id     int @id
+	author int @reference("users", "id")
+end
+`,
+			[]string{},
+		},
+		{
+			"aliases",
+			`
+Table users as U {
+  id int [pk]
+}
+
+Table posts {
+  id int [pk]
+  author int [not null, ref: > U.id]
+  editor int
+}
+
+Ref: posts.editor > U.id
+`,
+			`table users
+	id int @id
+end
+
+table posts
+	id     int @id
+	author int @reference("users", "id")
+	editor int @nullable @reference("users", "id")
+end
+`,
+			[]string{},
+		},
+		{
+			"enums",
+			`
+Enum status {
+  pending
+  shipped [note: 'On its way']
+}
+
+Table orders {
+  id int [pk]
+  status status [not null, default: 'pending']
+}
+`,
+			`table orders
+	id     int    @id
+	status string @default("pending")
+end
+`,
+			[]string{"Enum 'status' is not supported"},
+		},
+		{
+			"ref to the own table and many to many ref",
+			`
+Table users {
+  id int [pk]
+  manager int
+}
+
+Table tags {
+  id int [pk]
+}
+
+Ref: users.manager > users.id
+Ref: tags.id <> users.id
+`,
+			`table users
+	id      int @id
+	manager int @nullable @reference("users", "id")
+end
+
+table tags
+	id int @id
+end
+`,
+			[]string{"Many to many ref"},
+		},
+		{
+			"ref declared twice",
+			`
+Table users {
+  id int [pk]
+}
+
+Table posts {
+  id int [pk]
+  author int [not null, ref: > users.id]
+}
+
+Ref: posts.author > users.id [delete: cascade]
+`,
+			`table users
+	id int @id
+end
+
+table posts
+	id     int @id
+	author int @reference("users", "id")
+end
+`,
+			[]string{"'posts.author' already references a colmun"},
+		},
+		{
+			"composite index",
+			`
+Table users {
+  id int [pk]
+  first varchar
+  last varchar
+  email varchar
+
+  indexes {
+    email [unique]
+    (first, last) [unique]
+  }
+}
+`,
+			`table users
+	id    int    @id
+	first string @nullable
+	last  string @nullable
+	email string @nullable @unique
+end
+`,
+			[]string{"Index on (first, last)"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imported, warnings, err := ImportDBML(test.dbml)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if schema := Format(imported); schema != test.schema {
+				t.Errorf("schema:\n%s\nwant:\n%s", schema, test.schema)
+			}
+
+			if len(warnings) != len(test.warnings) {
+				t.Fatalf("warnings %q, want %d", warnings, len(test.warnings))
+			}
+			for i, warning := range test.warnings {
+				if !strings.Contains(warnings[i], warning) {
+					t.Errorf("warning %q does not mention %q", warnings[i], warning)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDBMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		dbml  string
+		error string
+	}{
+		{
+			"unknown table",
+			"Table posts {\n  id int [pk]\n  author int [ref: > U.id]\n}\n",
+			"unknown table",
+		},
+		{
+			"unknown colmun",
+			"Table users {\n  id int [pk]\n}\nTable posts {\n  author int [ref: > users.uid]\n}\n",
+			"unknown colmun",
+		},
+		{
+			"table declared twice",
+			"Table users {\n  id int\n}\nTable users {\n  id int\n}\n",
+			"already declared",
+		},
+		{
+			"alias declared twice",
+			"Table users as U {\n  id int\n}\nTable posts as U {\n  id int\n}\n",
+			"Alias 'U' already declared",
+		},
+		{
+			"unclosed table",
+			"Table users {\n  id int\n",
+			"Missing '}'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := ImportDBML(test.dbml)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+func TestGenerateDBMLRoundTrip(t *testing.T) {
+	schema := parseSchema(t, "postgresql", `
+table users
+	id int @id @auto_increment
+	email string(255) @unique
+end
+
+table posts
+	id int @id
+	author int @reference("users", "id") @onDelete("CASCADE")
+end
+`)
+
+	dbml, err := GenerateDBML(schema, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, _, err := ImportDBML(dbml)
+	if err != nil {
+		t.Fatalf("import of the generated dbml: %v\n%s", err, dbml)
+	}
+	if Format(imported) != Format(schema) {
+		t.Errorf("round trip changed the schema:\n%s\nwant:\n%s", Format(imported), Format(schema))
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// Diagnostic is a problem found in a schema, its Error text is what the cli
+// prints, the fields are what the json and sarif output is made of
+type Diagnostic struct {
+	Kind     string
+	Severity string
+	Code     string
+	Pos      Position
+	End      Position
+	Message  string
+}
+
+func (d *Diagnostic) Error() string {
+	label := "Error"
+	if d.Severity == severityWarning {
+		label = "Warning"
+	}
+
+	if d.Kind == "Lint" {
+		return fmt.Sprintf("%s %s:%s: %s [%s]", d.Kind, label, d.Pos, d.Message, d.Code)
+	}
+	return fmt.Sprintf("%s %s:%s: %s", d.Kind, label, d.Pos, d.Message)
+}
+
+// AsDiagnostics unwraps the diagnostics from an error returned by the parser
+// or the checks, errors.Join is looked through
+func AsDiagnostics(err error) ([]*Diagnostic, bool) {
+	if err == nil {
+		return []*Diagnostic{}, true
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		diagnostics := []*Diagnostic{}
+		for _, e := range joined.Unwrap() {
+			inner, ok := AsDiagnostics(e)
+			if !ok {
+				return nil, false
+			}
+			diagnostics = append(diagnostics, inner...)
+		}
+		return diagnostics, true
+	}
+
+	var diagnostic *Diagnostic
+	if errors.As(err, &diagnostic) {
+		return []*Diagnostic{diagnostic}, true
+	}
+
+	return nil, false
+}
+
+func sortDiagnostics(diagnostics []*Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Pos, diagnostics[j].Pos
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+}
+
+type jsonDiagnostic struct {
+	File     string       `json:"file"`
+	Line     int          `json:"line"`
+	Col      int          `json:"col"`
+	End      jsonPosition `json:"end"`
+	Severity string       `json:"severity"`
+	Code     string       `json:"code"`
+	Message  string       `json:"message"`
+}
+
+type jsonPosition struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+func FormatDiagnosticsJSON(diagnostics []*Diagnostic) (string, error) {
+	out := []jsonDiagnostic{}
+	for _, d := range diagnostics {
+		out = append(out, jsonDiagnostic{
+			File:     d.Pos.File,
+			Line:     d.Pos.Line,
+			Col:      d.Pos.Col,
+			End:      jsonPosition{d.End.Line, d.End.Col},
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+		})
+	}
+
+	content, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+// FormatDiagnosticsSARIF writes the diagnostics as a SARIF 2.1.0 log, the
+// format code scanning tools use to annotate pull requests
+func FormatDiagnosticsSARIF(diagnostics []*Diagnostic) (string, error) {
+	rules := []map[string]interface{}{}
+	seen := map[string]bool{}
+	results := []map[string]interface{}{}
+
+	for _, d := range diagnostics {
+		if !seen[d.Code] {
+			seen[d.Code] = true
+			rules = append(rules, map[string]interface{}{"id": d.Code})
+		}
+
+		region := map[string]interface{}{
+			"startLine":   d.Pos.Line,
+			"startColumn": d.Pos.Col,
+			"endLine":     d.End.Line,
+			"endColumn":   d.End.Col,
+		}
+
+		results = append(results, map[string]interface{}{
+			"ruleId":  d.Code,
+			"level":   d.Severity,
+			"message": map[string]string{"text": d.Message},
+			"locations": []interface{}{
+				map[string]interface{}{
+					"physicalLocation": map[string]interface{}{
+						"artifactLocation": map[string]string{
+							"uri": strings.ReplaceAll(d.Pos.File, "\\", "/"),
+						},
+						"region": region,
+					},
+				},
+			},
+		})
+	}
+
+	log := map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":           "sql-mi",
+						"version":        version,
+						"informationUri": "https://github.com/Blackarrow299/sql-mi",
+						"rules":          rules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+
+	content, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
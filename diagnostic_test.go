@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      string
+		code        int
+		diagnostics []jsonDiagnostic
+	}{
+		{
+			"valid schema",
+			"table users\n\tid int @id\nend\n",
+			exitOK,
+			[]jsonDiagnostic{},
+		},
+		{
+			"syntax error",
+			"table users\n\tname string(\nend\n",
+			exitSchemaError,
+			[]jsonDiagnostic{
+				{Line: 3, Col: 15, End: jsonPosition{3, 18}, Severity: "error", Code: "syntax-error", Message: "Expected number in type parameters, got 'EOL'"},
+			},
+		},
+		{
+			"unknown type",
+			"table users\n\tname strin\nend\n",
+			exitSchemaError,
+			[]jsonDiagnostic{
+				{Line: 3, Col: 2, End: jsonPosition{3, 6}, Severity: "error", Code: "unknown-type", Message: "Unknown data type 'strin' for colmun 'name'"},
+			},
+		},
+		{
+			"every invalid default",
+			"table users\n\tid int @default(\"x\")\n\tprice float @default(\"y\")\nend\n",
+			exitSchemaError,
+			[]jsonDiagnostic{
+				{Line: 3, Col: 18, End: jsonPosition{3, 21}, Severity: "error", Code: "invalid-default", Message: "Invalid default value \"x\" for colmun 'id' of type int: expected an integer"},
+				{Line: 4, Col: 23, End: jsonPosition{4, 26}, Severity: "error", Code: "invalid-default", Message: "Invalid default value \"y\" for colmun 'price' of type float: expected a number"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeSchema(t, t.TempDir(), "schema.sqmi", "set provider sqlite\n"+test.schema)
+
+			code := exitOK
+			out := withStdio(t, "", func() {
+				code = Run([]string{"validate", "--format", "json", path})
+			})
+			if code != test.code {
+				t.Errorf("exit code %d, want %d", code, test.code)
+			}
+
+			diagnostics := []jsonDiagnostic{}
+			err := json.Unmarshal([]byte(out), &diagnostics)
+			if err != nil {
+				t.Fatalf("%v in:\n%s", err, out)
+			}
+			for i := range test.diagnostics {
+				test.diagnostics[i].File = path
+			}
+			if !reflect.DeepEqual(diagnostics, test.diagnostics) {
+				t.Errorf("diagnostics %+v, want %+v", diagnostics, test.diagnostics)
+			}
+		})
+	}
+}
+
+func TestFormatDiagnosticsSARIF(t *testing.T) {
+	diagnostics := []*Diagnostic{
+		{Kind: "Type", Severity: "error", Code: "unknown-type", Pos: Position{`schema\users.sqmi`, 2, 3}, End: Position{`schema\users.sqmi`, 2, 7}, Message: "Unknown data type"},
+		{Kind: "Lint", Severity: "warning", Code: "naming", Pos: Position{"posts.sqmi", 1, 7}, End: Position{"posts.sqmi", 1, 12}, Message: "Table name"},
+		{Kind: "Type", Severity: "error", Code: "unknown-type", Pos: Position{"posts.sqmi", 4, 2}, End: Position{"posts.sqmi", 4, 5}, Message: "Unknown data type"},
+	}
+
+	out, err := FormatDiagnosticsSARIF(diagnostics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct{ Id string }
+				}
+			}
+			Results []struct {
+				RuleId    string
+				Level     string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ Uri string }
+						Region           struct{ StartLine, StartColumn, EndLine, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+	err = json.Unmarshal([]byte(out), &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Name != "sql-mi" {
+		t.Fatalf("unexpected log:\n%s", out)
+	}
+	run := log.Runs[0]
+
+	rules := []string{}
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.Id)
+	}
+	if !reflect.DeepEqual(rules, []string{"unknown-type", "naming"}) {
+		t.Errorf("rules %q, want each code once", rules)
+	}
+
+	if len(run.Results) != 3 {
+		t.Fatalf("%d results, want 3", len(run.Results))
+	}
+	result := run.Results[0]
+	location := result.Locations[0].PhysicalLocation
+	if result.RuleId != "unknown-type" || result.Level != "error" || location.ArtifactLocation.Uri != "schema/users.sqmi" {
+		t.Errorf("result %+v, want unknown-type error in schema/users.sqmi", result)
+	}
+	if location.Region.StartLine != 2 || location.Region.StartColumn != 3 || location.Region.EndLine != 2 || location.Region.EndColumn != 7 {
+		t.Errorf("region %+v, want 2:3 to 2:7", location.Region)
+	}
+	if run.Results[1].Level != "warning" {
+		t.Errorf("level %q, want warning", run.Results[1].Level)
+	}
+}
+
+func TestDiagnosticError(t *testing.T) {
+	pos := Position{"schema.sqmi", 3, 5}
+
+	tests := []struct {
+		diagnostic *Diagnostic
+		text       string
+	}{
+		{&Diagnostic{Kind: "Syntax", Severity: "error", Code: "syntax-error", Pos: pos, Message: "Unexpected token"}, "Syntax Error:schema.sqmi:3:5: Unexpected token"},
+		{&Diagnostic{Kind: "Type", Severity: "error", Code: "unknown-type", Pos: pos, Message: "Unknown data type"}, "Type Error:schema.sqmi:3:5: Unknown data type"},
+		{&Diagnostic{Kind: "Lint", Severity: "warning", Code: "naming", Pos: pos, Message: "Table name"}, "Lint Warning:schema.sqmi:3:5: Table name [naming]"},
+	}
+
+	for _, test := range tests {
+		if text := test.diagnostic.Error(); text != test.text {
+			t.Errorf("error %q, want %q", text, test.text)
+		}
+	}
+}
+
+func TestAsDiagnostics(t *testing.T) {
+	first := &Diagnostic{Kind: "Type", Message: "first"}
+	second := &Diagnostic{Kind: "Type", Message: "second"}
+
+	tests := []struct {
+		name        string
+		err         error
+		diagnostics []*Diagnostic
+		ok          bool
+	}{
+		{"no error", nil, []*Diagnostic{}, true},
+		{"diagnostic", first, []*Diagnostic{first}, true},
+		{"joined diagnostics", errors.Join(first, second), []*Diagnostic{first, second}, true},
+		{"plain error", errors.New("Error: boom"), nil, false},
+		{"joined with a plain error", errors.Join(first, errors.New("Error: boom")), nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diagnostics, ok := AsDiagnostics(test.err)
+			if ok != test.ok || !reflect.DeepEqual(diagnostics, test.diagnostics) {
+				t.Errorf("diagnostics %v %v, want %v %v", diagnostics, ok, test.diagnostics, test.ok)
+			}
+		})
+	}
+}
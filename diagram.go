@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// what mermaid does not accept in an attribute type
+var mermaidTypeChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+var diagramFormats = map[string]func(ast *AST) string{
+	"mermaid": GenerateMermaid,
+	"dot":     GenerateDot,
+}
+
+// GenerateMermaid writes a mermaid erDiagram, one entity per table and one
+// relationship per reference
+func GenerateMermaid(ast *AST) string {
+	lines := []string{"erDiagram"}
+
+	for _, table := range ast.Tables {
+		lines = append(lines, fmt.Sprintf("    %s {", table.Name))
+		for _, colmun := range table.Colmuns {
+			line := fmt.Sprintf("        %s %s", mermaidType(colmun), colmun.Name)
+
+			keys := colmunKeys(table, colmun)
+			if len(keys) > 0 {
+				line += " " + strings.Join(keys, ",")
+			}
+			if isNullable(colmun) {
+				line += ` "nullable"`
+			}
+
+			lines = append(lines, line)
+		}
+		lines = append(lines, "    }")
+	}
+
+	for _, table := range ast.Tables {
+		for _, ref := range table.References {
+			// the referenced row is optional when the foreign key can be null
+			one := "||"
+			if colmun := findColmun(table, ref.SourceCol); colmun != nil && isNullable(colmun) {
+				one = "|o"
+			}
+
+			lines = append(lines, fmt.Sprintf(
+				"    %s %s--o{ %s : \"%s\"",
+				ref.TargetTable,
+				one,
+				table.Name,
+				referenceLabel(ref),
+			))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// mermaidType writes the type of a colmun as one word, varchar(255) becomes
+// varchar_255
+func mermaidType(colmun *ColmunAST) string {
+	name := strings.Trim(formatColmunType(colmun), "`")
+	name = strings.Trim(mermaidTypeChars.ReplaceAllString(name, "_"), "_")
+	if len(name) == 0 {
+		return colmun.Data_type
+	}
+	return name
+}
+
+// GenerateDot writes a graphviz digraph, every table is a record whose
+// fields are the colmuns so edges go from colmun to colmun
+func GenerateDot(ast *AST) string {
+	lines := []string{
+		"digraph schema {",
+		"    rankdir=LR;",
+		"    node [shape=record, fontname=\"Helvetica\"];",
+		"    edge [fontname=\"Helvetica\", fontsize=10];",
+	}
+
+	for _, table := range ast.Tables {
+		fields := []string{}
+		for _, colmun := range table.Colmuns {
+			field := fmt.Sprintf("%s : %s", colmun.Name, formatColmunType(colmun))
+			if isNullable(colmun) {
+				field += "?"
+			}
+
+			keys := colmunKeys(table, colmun)
+			if len(keys) > 0 {
+				field += " (" + strings.Join(keys, ", ") + ")"
+			}
+
+			fields = append(fields, fmt.Sprintf("<%s> %s\\l", colmun.Name, dotEscape(field)))
+		}
+
+		lines = append(lines, fmt.Sprintf(
+			"    \"%s\" [label=\"{%s|%s}\"];",
+			table.Name,
+			dotEscape(table.Name),
+			strings.Join(fields, ""),
+		))
+	}
+
+	for _, table := range ast.Tables {
+		for _, ref := range table.References {
+			label := "N:1"
+			if colmun := findColmun(table, ref.SourceCol); colmun != nil && isNullable(colmun) {
+				label = "N:0..1"
+			}
+			if actions := referenceActions(ref); len(actions) > 0 {
+				label += "\\n" + actions
+			}
+
+			lines = append(lines, fmt.Sprintf(
+				"    \"%s\":\"%s\" -> \"%s\":\"%s\" [label=\"%s\"];",
+				table.Name,
+				ref.SourceCol,
+				ref.TargetTable,
+				ref.TargetCol,
+				label,
+			))
+		}
+	}
+
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func colmunKeys(table *TabelAST, colmun *ColmunAST) []string {
+	keys := []string{}
+	if _, exists := (*colmun.Attributes)["id"]; exists {
+		keys = append(keys, "PK")
+	}
+	if _, exists := (*colmun.Attributes)["unique"]; exists {
+		keys = append(keys, "UK")
+	}
+	for _, ref := range table.References {
+		if ref.SourceCol == colmun.Name {
+			keys = append(keys, "FK")
+			break
+		}
+	}
+	return keys
+}
+
+func isNullable(colmun *ColmunAST) bool {
+	_, exists := (*colmun.Attributes)["nullable"]
+	return exists
+}
+
+func referenceLabel(ref *ReferenceAST) string {
+	label := ref.SourceCol
+	actions := referenceActions(ref)
+	if len(actions) > 0 {
+		label += ", " + actions
+	}
+	return label
+}
+
+func referenceActions(ref *ReferenceAST) string {
+	actions := []string{}
+	if len(ref.OnDelete) > 0 {
+		actions = append(actions, "ON DELETE "+ref.OnDelete)
+	}
+	if len(ref.OnUpdate) > 0 {
+		actions = append(actions, "ON UPDATE "+ref.OnUpdate)
+	}
+	return strings.Join(actions, ", ")
+}
+
+// characters with a meaning in record labels
+func dotEscape(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"{", `\{`,
+		"}", `\}`,
+		"|", `\|`,
+		"<", `\<`,
+		">", `\>`,
+	)
+	return replacer.Replace(s)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateDiagram(t *testing.T) {
+	schema := parseSchema(t, "postgresql", `
+table users
+	id int @id
+	email string(255) @unique
+	span `+"`INTERVAL DAY`"+`
+end
+table posts
+	id int @id
+	user_id int @reference("users", "id") @onDelete("CASCADE")
+	editor_id int @nullable @reference("users", "id")
+	price decimal(10, 2)
+end
+`)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"mermaid", `erDiagram
+    users {
+        int id PK
+        string_255 email UK
+        INTERVAL_DAY span
+    }
+    posts {
+        int id PK
+        int user_id FK
+        int editor_id FK "nullable"
+        decimal_10_2 price
+    }
+    users ||--o{ posts : "user_id, ON DELETE CASCADE"
+    users |o--o{ posts : "editor_id"
+`},
+		{"dot", `digraph schema {
+    rankdir=LR;
+    node [shape=record, fontname="Helvetica"];
+    edge [fontname="Helvetica", fontsize=10];
+    "users" [label="{users|<id> id : int (PK)\l<email> email : string(255) (UK)\l<span> span : ` + "`INTERVAL DAY`" + `\l}"];
+    "posts" [label="{posts|<id> id : int (PK)\l<user_id> user_id : int (FK)\l<editor_id> editor_id : int? (FK)\l<price> price : decimal(10, 2)\l}"];
+    "posts":"user_id" -> "users":"id" [label="N:1\nON DELETE CASCADE"];
+    "posts":"editor_id" -> "users":"id" [label="N:0..1"];
+}
+`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			diagram := diagramFormats[test.format](schema)
+			if diagram != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", diagram, test.want)
+			}
+		})
+	}
+}
+
+func TestMermaidType(t *testing.T) {
+	tests := []struct {
+		colmun string
+		want   string
+	}{
+		{"a int", "int"},
+		{"a string(255)", "string_255"},
+		{"a decimal(10, 2)", "decimal_10_2"},
+		{"a `DOUBLE PRECISION`", "DOUBLE_PRECISION"},
+		{"a `INT[]`", "INT"},
+		{"a `()`", "raw"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.colmun, func(t *testing.T) {
+			schema := parseSchema(t, "postgresql", "table t\n\t"+test.colmun+"\nend\n")
+			if got := mermaidType(schema.Tables[0].Colmuns[0]); got != test.want {
+				t.Errorf("type %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDotEscape(t *testing.T) {
+	got := dotEscape(`a|b {c} <d> "e" \f`)
+	want := `a\|b \{c\} \<d\> \"e\" \\f`
+	if got != want {
+		t.Errorf("escaped %q, want %q", got, want)
+	}
+}
+
+func TestParseDiagramArgs(t *testing.T) {
+	_, err := ParseDiagramArgs([]string{"--format", "plantuml", "schema.sqmi"})
+	if err == nil || !strings.Contains(err.Error(), "Unknown format 'plantuml', expected mermaid or dot") {
+		t.Errorf("error %v, want an unknown format", err)
+	}
+}
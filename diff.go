@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SchemaDiff struct {
+	AddedTables   []*TabelAST
+	DroppedTables []*TabelAST
+	ChangedTables []*TableDiff
+}
+
+type TableDiff struct {
+	Old            *TabelAST
+	New            *TabelAST
+	AddedColmuns   []*ColmunAST
+	DroppedColmuns []*ColmunAST
+	ChangedColmuns []*ColmunDiff
+}
+
+type ColmunDiff struct {
+	Old     *ColmunAST
+	New     *ColmunAST
+	Changes []string
+}
+
+// Diff compares two schemas table by table and colmun by colmun, tables and
+// colmuns are matched by name or by a @renamedFrom hint
+func Diff(oldAst *AST, newAst *AST) *SchemaDiff {
+	diff := &SchemaDiff{}
+	matched := map[*TabelAST]*TabelAST{}
+
+	// old names of renamed tables and of renamed table.colmun, so references
+	// to them are not reported as changed
+	renames := map[string]string{}
+
+	for _, newTable := range newAst.Tables {
+		oldTable := findRenamedTable(oldAst, newAst, newTable)
+		if oldTable == nil {
+			continue
+		}
+		matched[newTable] = oldTable
+
+		if oldTable.Name != newTable.Name {
+			renames[oldTable.Name] = newTable.Name
+		}
+		for _, newCol := range newTable.Colmuns {
+			oldCol := findRenamedColmun(oldTable, newTable, newCol)
+			if oldCol != nil && oldCol.Name != newCol.Name {
+				renames[oldTable.Name+"."+oldCol.Name] = newCol.Name
+			}
+		}
+	}
+
+	dropped := map[*TabelAST]bool{}
+	for _, oldTable := range oldAst.Tables {
+		dropped[oldTable] = true
+	}
+
+	for _, newTable := range newAst.Tables {
+		oldTable, exists := matched[newTable]
+		if !exists {
+			diff.AddedTables = append(diff.AddedTables, newTable)
+			continue
+		}
+		delete(dropped, oldTable)
+
+		tableDiff := diffTable(oldTable, newTable, renames)
+		if !tableDiff.IsEmpty() {
+			diff.ChangedTables = append(diff.ChangedTables, tableDiff)
+		}
+	}
+
+	for _, oldTable := range oldAst.Tables {
+		if dropped[oldTable] {
+			diff.DroppedTables = append(diff.DroppedTables, oldTable)
+		}
+	}
+
+	return diff
+}
+
+// findRenamedTable finds the old table of newTable by name, then by the hint
+// of the new table, then by the hint of an old table so the diff of a down
+// migration finds renames as well
+func findRenamedTable(oldAst *AST, newAst *AST, newTable *TabelAST) *TabelAST {
+	if oldTable := findTable(oldAst, newTable.Name); oldTable != nil {
+		return oldTable
+	}
+
+	if previous := renamedFrom(newTable.Attributes); len(previous) > 0 && findTable(newAst, previous) == nil {
+		if oldTable := findTable(oldAst, previous); oldTable != nil {
+			return oldTable
+		}
+	}
+
+	for _, oldTable := range oldAst.Tables {
+		if renamedFrom(oldTable.Attributes) == newTable.Name && findTable(newAst, oldTable.Name) == nil {
+			return oldTable
+		}
+	}
+	return nil
+}
+
+func diffTable(oldTable *TabelAST, newTable *TabelAST, renames map[string]string) *TableDiff {
+	tableDiff := &TableDiff{Old: oldTable, New: newTable}
+	matched := map[*ColmunAST]bool{}
+
+	for _, newCol := range newTable.Colmuns {
+		oldCol := findRenamedColmun(oldTable, newTable, newCol)
+		if oldCol == nil {
+			tableDiff.AddedColmuns = append(tableDiff.AddedColmuns, newCol)
+			continue
+		}
+		matched[oldCol] = true
+
+		changes := diffColmun(oldCol, oldTable, newCol, newTable, renames)
+		if len(changes) > 0 || oldCol.Name != newCol.Name {
+			tableDiff.ChangedColmuns = append(
+				tableDiff.ChangedColmuns,
+				&ColmunDiff{oldCol, newCol, changes},
+			)
+		}
+	}
+
+	for _, oldCol := range oldTable.Colmuns {
+		if !matched[oldCol] {
+			tableDiff.DroppedColmuns = append(tableDiff.DroppedColmuns, oldCol)
+		}
+	}
+
+	return tableDiff
+}
+
+// findRenamedColmun is findRenamedTable for the colmuns of a table
+func findRenamedColmun(oldTable *TabelAST, newTable *TabelAST, newCol *ColmunAST) *ColmunAST {
+	if oldCol := findColmun(oldTable, newCol.Name); oldCol != nil {
+		return oldCol
+	}
+
+	if previous := renamedFrom(newCol.Attributes); len(previous) > 0 && findColmun(newTable, previous) == nil {
+		if oldCol := findColmun(oldTable, previous); oldCol != nil {
+			return oldCol
+		}
+	}
+
+	for _, oldCol := range oldTable.Colmuns {
+		if renamedFrom(oldCol.Attributes) == newCol.Name && findColmun(newTable, oldCol.Name) == nil {
+			return oldCol
+		}
+	}
+	return nil
+}
+
+// renamedFrom gives the name of a @renamedFrom hint, empty without one
+func renamedFrom(attributes *AttributesAST) string {
+	if attributes == nil {
+		return ""
+	}
+	attr, exists := (*attributes)["renamedFrom"]
+	if !exists || len(attr.Values) != 1 {
+		return ""
+	}
+	return attr.Values[0].Value
+}
+
+func diffColmun(oldCol *ColmunAST, oldTable *TabelAST, newCol *ColmunAST, newTable *TabelAST, renames map[string]string) []string {
+	oldProps := colmunProperties(oldCol, oldTable, renames)
+	newProps := colmunProperties(newCol, newTable, nil)
+
+	keys := []string{}
+	for key := range oldProps {
+		keys = append(keys, key)
+	}
+	for key := range newProps {
+		if _, exists := oldProps[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []string{}
+	for _, key := range keys {
+		oldValue, newValue := oldProps[key], newProps[key]
+		if oldValue == newValue {
+			continue
+		}
+		if len(oldValue) == 0 {
+			oldValue = "none"
+		}
+		if len(newValue) == 0 {
+			newValue = "none"
+		}
+		changes = append(changes, fmt.Sprintf("%s %s -> %s", key, oldValue, newValue))
+	}
+
+	return changes
+}
+
+// everything that makes up a colmun definition, as comparable strings.
+// References to renamed tables and colmuns use their new names
+func colmunProperties(colmun *ColmunAST, table *TabelAST, renames map[string]string) map[string]string {
+	props := map[string]string{
+		"type": formatColmunType(colmun),
+	}
+
+	for _, attr := range *colmun.Attributes {
+		if attr.Name == "raw" || attr.Name == "renamedFrom" {
+			continue
+		}
+		props[attr.Name] = formatAttribute(attr.Name, attr.Values)
+	}
+
+	for _, ref := range table.References {
+		if ref.SourceCol != colmun.Name {
+			continue
+		}
+		target, targetCol := ref.TargetTable, ref.TargetCol
+		if renamed, exists := renames[ref.TargetTable+"."+ref.TargetCol]; exists {
+			targetCol = renamed
+		}
+		if renamed, exists := renames[ref.TargetTable]; exists {
+			target = renamed
+		}
+		props["reference"] = fmt.Sprintf("%s(%s)", target, targetCol)
+		if len(ref.OnDelete) > 0 {
+			props["onDelete"] = ref.OnDelete
+		}
+		if len(ref.OnUpdate) > 0 {
+			props["onUpdate"] = ref.OnUpdate
+		}
+	}
+
+	return props
+}
+
+func findTable(ast *AST, name string) *TabelAST {
+	for _, table := range ast.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+func findColmun(table *TabelAST, name string) *ColmunAST {
+	for _, colmun := range table.Colmuns {
+		if colmun.Name == name {
+			return colmun
+		}
+	}
+	return nil
+}
+
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.AddedTables) == 0 && len(d.DroppedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+func (d *TableDiff) IsEmpty() bool {
+	return !d.IsRenamed() && len(d.AddedColmuns) == 0 && len(d.DroppedColmuns) == 0 && len(d.ChangedColmuns) == 0
+}
+
+func (d *TableDiff) IsRenamed() bool {
+	return d.Old.Name != d.New.Name
+}
+
+// HasColmunChanges tells whether anything besides the names changed
+func (d *TableDiff) HasColmunChanges() bool {
+	if len(d.AddedColmuns) > 0 || len(d.DroppedColmuns) > 0 {
+		return true
+	}
+	for _, colDiff := range d.ChangedColmuns {
+		if len(colDiff.Changes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// String gives a readable summary, + for added, - for dropped and ~ for changed
+func (d *SchemaDiff) String() string {
+	builder := strings.Builder{}
+
+	for _, table := range d.AddedTables {
+		builder.WriteString(fmt.Sprintf("+ table %s\n", table.Name))
+	}
+
+	for _, table := range d.DroppedTables {
+		builder.WriteString(fmt.Sprintf("- table %s\n", table.Name))
+	}
+
+	for _, tableDiff := range d.ChangedTables {
+		if tableDiff.IsRenamed() {
+			builder.WriteString(fmt.Sprintf("~ table %s -> %s\n", tableDiff.Old.Name, tableDiff.New.Name))
+		} else {
+			builder.WriteString(fmt.Sprintf("~ table %s\n", tableDiff.New.Name))
+		}
+
+		for _, colmun := range tableDiff.AddedColmuns {
+			builder.WriteString(fmt.Sprintf("\t+ %s %s\n", colmun.Name, formatColmunType(colmun)))
+		}
+
+		for _, colmun := range tableDiff.DroppedColmuns {
+			builder.WriteString(fmt.Sprintf("\t- %s\n", colmun.Name))
+		}
+
+		for _, colDiff := range tableDiff.ChangedColmuns {
+			name := colDiff.New.Name
+			if colDiff.Old.Name != colDiff.New.Name {
+				name = colDiff.Old.Name + " -> " + colDiff.New.Name
+			}
+			if len(colDiff.Changes) == 0 {
+				builder.WriteString(fmt.Sprintf("\t~ %s\n", name))
+				continue
+			}
+			builder.WriteString(fmt.Sprintf("\t~ %s: %s\n", name, strings.Join(colDiff.Changes, ", ")))
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffRenames(t *testing.T) {
+	users := "table users\n\tid int @id\n\tname string\nend\n"
+	posts := "table posts\n\tid int @id\n\tauthor int @reference(\"users\", \"id\")\nend\n"
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		diff string
+	}{
+		{
+			"no hint drops and adds",
+			users,
+			"table users\n\tid int @id\n\tfull_name string\nend\n",
+			"~ table users\n\t+ full_name string\n\t- name\n",
+		},
+		{
+			"renamed colmun",
+			users,
+			"table users\n\tid int @id\n\tfull_name string @renamedFrom(\"name\")\nend\n",
+			"~ table users\n\t~ name -> full_name\n",
+		},
+		{
+			"renamed colmun with a new type",
+			users,
+			"table users\n\tid int @id\n\tfull_name text @renamedFrom(\"name\")\nend\n",
+			"~ table users\n\t~ name -> full_name: type string -> text\n",
+		},
+		{
+			"renamed table",
+			users,
+			"table people @renamedFrom(\"users\")\n\tid int @id\n\tname string\nend\n",
+			"~ table users -> people\n",
+		},
+		{
+			"hint on the old side, as in a down migration",
+			"table people @renamedFrom(\"users\")\n\tid int @id\n\tfull_name string @renamedFrom(\"name\")\nend\n",
+			users,
+			"~ table people -> users\n\t~ full_name -> name\n",
+		},
+		{
+			"hint to a name that is still declared",
+			users,
+			"table users\n\tid int @id\n\tname string\n\tfull_name string @renamedFrom(\"name\")\nend\n",
+			"~ table users\n\t+ full_name string\n",
+		},
+		{
+			"hint to a name the old schema does not have",
+			users,
+			"table users\n\tid int @id\n\tfull_name string @renamedFrom(\"nickname\")\nend\n",
+			"~ table users\n\t+ full_name string\n\t- name\n",
+		},
+		{
+			"reference to a renamed table and colmun",
+			users + posts,
+			"table people @renamedFrom(\"users\")\n\tuid int @id @renamedFrom(\"id\")\n\tname string\nend\n" +
+				"table posts\n\tid int @id\n\tauthor int @reference(\"people\", \"uid\")\nend\n",
+			"~ table users -> people\n\t~ id -> uid\n",
+		},
+		{
+			"hint already applied",
+			"table people @renamedFrom(\"users\")\n\tid int @id\n\tname string\nend\n",
+			"table people @renamedFrom(\"users\")\n\tid int @id\n\tname string\nend\n",
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := Diff(parseSchema(t, "sqlite", test.old), parseSchema(t, "sqlite", test.new))
+			if got := diff.String(); got != test.diff {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.diff)
+			}
+		})
+	}
+}
+
+func TestParseRenamedFromErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		error  string
+	}{
+		{"colmun without a parameter", "table users\n\tid int @renamedFrom\nend\n", "@renamedFrom takes one string parameter"},
+		{"colmun with a raw value", "table users\n\tid int @renamedFrom(`uid`)\nend\n", "@renamedFrom takes one string parameter"},
+		{"table with two parameters", "table users @renamedFrom(\"a\", \"b\")\n\tid int\nend\n", "@renamedFrom takes one string parameter"},
+		{"other table attribute", "table users @unique\n\tid int\nend\n", "Unknown table attribute @unique"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(NewTokenizer(test.schema))
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
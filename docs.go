@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// docWriter renders the pages of the docs target, inline helpers return
+// text that can be put in headings, paragraphs, lists and table cells
+type docWriter interface {
+	Heading(level int, text string)
+	Paragraph(text string)
+	List(items []string)
+	Table(headers []string, rows [][]string)
+
+	Text(s string) string
+	Code(s string) string
+	Link(text string, href string) string
+
+	String() string
+}
+
+// GenerateDocs writes an index page and one page per table, as markdown or
+// html depending on the format
+func GenerateDocs(ast *AST, cfg *Config) (map[string]string, error) {
+	initValues()
+
+	ext := ".md"
+	newWriter := func(title string) docWriter { return &markdownWriter{} }
+	if cfg.Format == "html" {
+		ext = ".html"
+		newWriter = func(title string) docWriter { return &htmlWriter{title: title} }
+	}
+
+	files := map[string]string{}
+
+	index := newWriter("Schema")
+	writeDocsIndex(index, ast, ext)
+	files["index"+ext] = index.String()
+
+	for _, table := range ast.Tables {
+		page := newWriter(table.Name)
+		err := writeDocsTable(page, ast, table, ext)
+		if err != nil {
+			return nil, err
+		}
+		files[table.Name+ext] = page.String()
+	}
+
+	return files, nil
+}
+
+func writeDocsIndex(w docWriter, ast *AST, ext string) {
+	w.Heading(1, "Schema")
+	w.Paragraph(fmt.Sprintf("Provider: %s", w.Code(ast.Configuration["provider"])))
+
+	rows := [][]string{}
+	for _, table := range ast.Tables {
+		rows = append(rows, []string{
+			w.Link(w.Code(table.Name), table.Name+ext),
+			fmt.Sprint(len(table.Colmuns)),
+			w.Text(firstLine(table.Doc)),
+		})
+	}
+	w.Table([]string{"Table", "Colmuns", "Description"}, rows)
+}
+
+func writeDocsTable(w docWriter, ast *AST, table *TabelAST, ext string) error {
+	w.Heading(1, w.Code(table.Name))
+	w.Paragraph(w.Link("Back to the index", "index"+ext))
+
+	for _, paragraph := range strings.Split(table.Doc, "\n\n") {
+		if len(strings.TrimSpace(paragraph)) > 0 {
+			w.Paragraph(w.Text(paragraph))
+		}
+	}
+
+	w.Heading(2, "Colmuns")
+
+	headers := []string{"Colmun", "Type"}
+	for _, p := range providers {
+		headers = append(headers, w.Text(string(p)))
+	}
+	headers = append(headers, "Nullable", "Default", "Constraints", "Description")
+
+	rows := [][]string{}
+	for _, colmun := range table.Colmuns {
+		row := []string{w.Code(colmun.Name), w.Code(formatColmunType(colmun))}
+
+		for _, p := range providers {
+			sqlType, err := providerType(colmun, p)
+			if err != nil {
+				return err
+			}
+			row = append(row, w.Code(sqlType))
+		}
+
+		nullable := "no"
+		if isNullable(colmun) {
+			nullable = "yes"
+		}
+
+		defaultValue := ""
+		if attr, exists := (*colmun.Attributes)["default"]; exists && len(attr.Values) == 1 {
+			defaultValue = w.Code(attr.Values[0].Value)
+		}
+
+		row = append(
+			row,
+			nullable,
+			defaultValue,
+			w.Text(strings.Join(colmunConstraints(table, colmun), ", ")),
+			w.Text(strings.ReplaceAll(colmun.Doc, "\n", " ")),
+		)
+		rows = append(rows, row)
+	}
+	w.Table(headers, rows)
+
+	outgoing := []string{}
+	for _, ref := range table.References {
+		item := fmt.Sprintf(
+			"%s references %s.%s",
+			w.Code(ref.SourceCol),
+			w.Link(w.Code(ref.TargetTable), ref.TargetTable+ext),
+			w.Code(ref.TargetCol),
+		)
+		if actions := referenceActions(ref); len(actions) > 0 {
+			item += w.Text(", " + actions)
+		}
+		outgoing = append(outgoing, item)
+	}
+
+	incoming := []string{}
+	for _, other := range ast.Tables {
+		for _, ref := range other.References {
+			if ref.TargetTable != table.Name {
+				continue
+			}
+			incoming = append(incoming, fmt.Sprintf(
+				"%s.%s references %s",
+				w.Link(w.Code(other.Name), other.Name+ext),
+				w.Code(ref.SourceCol),
+				w.Code(ref.TargetCol),
+			))
+		}
+	}
+
+	if len(outgoing) > 0 {
+		w.Heading(2, "References")
+		w.List(outgoing)
+	}
+
+	if len(incoming) > 0 {
+		w.Heading(2, "Referenced by")
+		w.List(incoming)
+	}
+
+	return nil
+}
+
+func colmunConstraints(table *TabelAST, colmun *ColmunAST) []string {
+	constraints := []string{}
+	if _, exists := (*colmun.Attributes)["id"]; exists {
+		constraints = append(constraints, "primary key")
+	}
+	if _, exists := (*colmun.Attributes)["auto_increment"]; exists {
+		constraints = append(constraints, "auto increment")
+	}
+	if _, exists := (*colmun.Attributes)["unique"]; exists {
+		constraints = append(constraints, "unique")
+	}
+	for _, ref := range table.References {
+		if ref.SourceCol == colmun.Name {
+			constraints = append(constraints, "foreign key")
+		}
+	}
+	return constraints
+}
+
+// providerType gives the sql type a colmun has for a provider
+func providerType(colmun *ColmunAST, p Provider) (string, error) {
+	previous := provider
+	provider = p
+	defer func() { provider = previous }()
+
+	return getType(colmun)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+type markdownWriter struct {
+	blocks []string
+}
+
+func (w *markdownWriter) Heading(level int, text string) {
+	w.blocks = append(w.blocks, strings.Repeat("#", level)+" "+text)
+}
+
+func (w *markdownWriter) Paragraph(text string) {
+	w.blocks = append(w.blocks, text)
+}
+
+func (w *markdownWriter) List(items []string) {
+	lines := []string{}
+	for _, item := range items {
+		lines = append(lines, "- "+item)
+	}
+	w.blocks = append(w.blocks, strings.Join(lines, "\n"))
+}
+
+func (w *markdownWriter) Table(headers []string, rows [][]string) {
+	separators := []string{}
+	for range headers {
+		separators = append(separators, "---")
+	}
+
+	lines := []string{
+		"| " + strings.Join(headers, " | ") + " |",
+		"| " + strings.Join(separators, " | ") + " |",
+	}
+	for _, row := range rows {
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+	}
+	w.blocks = append(w.blocks, strings.Join(lines, "\n"))
+}
+
+// doc comments are written as markdown already, only what would break a
+// table is escaped
+func (w *markdownWriter) Text(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func (w *markdownWriter) Code(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+func (w *markdownWriter) Link(text string, href string) string {
+	return fmt.Sprintf("[%s](%s)", text, href)
+}
+
+func (w *markdownWriter) String() string {
+	return strings.Join(w.blocks, "\n\n") + "\n"
+}
+
+type htmlWriter struct {
+	title string
+	body  []string
+}
+
+func (w *htmlWriter) Heading(level int, text string) {
+	w.body = append(w.body, fmt.Sprintf("<h%d>%s</h%d>", level, text, level))
+}
+
+func (w *htmlWriter) Paragraph(text string) {
+	w.body = append(w.body, "<p>"+text+"</p>")
+}
+
+func (w *htmlWriter) List(items []string) {
+	lines := []string{"<ul>"}
+	for _, item := range items {
+		lines = append(lines, "  <li>"+item+"</li>")
+	}
+	lines = append(lines, "</ul>")
+	w.body = append(w.body, strings.Join(lines, "\n"))
+}
+
+func (w *htmlWriter) Table(headers []string, rows [][]string) {
+	lines := []string{"<table>", "  <tr><th>" + strings.Join(headers, "</th><th>") + "</th></tr>"}
+	for _, row := range rows {
+		lines = append(lines, "  <tr><td>"+strings.Join(row, "</td><td>")+"</td></tr>")
+	}
+	lines = append(lines, "</table>")
+	w.body = append(w.body, strings.Join(lines, "\n"))
+}
+
+func (w *htmlWriter) Text(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
+
+func (w *htmlWriter) Code(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return "<code>" + html.EscapeString(s) + "</code>"
+}
+
+func (w *htmlWriter) Link(text string, href string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), text)
+}
+
+const docsStyle = `body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+code { background: #f4f4f4; }`
+
+func (w *htmlWriter) String() string {
+	return fmt.Sprintf(
+		"<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n%s\n</body>\n</html>\n",
+		html.EscapeString(w.title),
+		docsStyle,
+		strings.Join(w.body, "\n"),
+	)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const docsSchema = `/// People who can sign in.
+///
+/// Rows are never deleted.
+table users
+	/// Login | email
+	id    int         @id @auto_increment
+	email string(255) @default("a<b>") @unique
+end
+
+table posts
+	id      int @id
+	user_id int @nullable @reference("users", "id") @onDelete("CASCADE")
+end
+`
+
+func TestGenerateDocsMarkdown(t *testing.T) {
+	files, err := GenerateDocs(parseSchema(t, "sqlite", docsSchema), &Config{Format: "markdown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"index.md": "# Schema\n\nProvider: `sqlite`\n\n" +
+			"| Table | Colmuns | Description |\n" +
+			"| --- | --- | --- |\n" +
+			"| [`users`](users.md) | 2 | People who can sign in. |\n" +
+			"| [`posts`](posts.md) | 2 |  |\n",
+		"users.md": "# `users`\n\n[Back to the index](index.md)\n\nPeople who can sign in.\n\nRows are never deleted.\n\n## Colmuns\n\n" +
+			"| Colmun | Type | sqlite | postgresql | mysql | Nullable | Default | Constraints | Description |\n" +
+			"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+			"| `id` | `int` | `INTEGER` | `INTEGER` | `INT` | no |  | primary key, auto increment | Login \\| email |\n" +
+			"| `email` | `string(255)` | `TEXT` | `VARCHAR(255)` | `VARCHAR(255)` | no | `a<b>` | unique |  |\n\n" +
+			"## Referenced by\n\n- [`posts`](posts.md).`user_id` references `id`\n",
+		"posts.md": "# `posts`\n\n[Back to the index](index.md)\n\n## Colmuns\n\n" +
+			"| Colmun | Type | sqlite | postgresql | mysql | Nullable | Default | Constraints | Description |\n" +
+			"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+			"| `id` | `int` | `INTEGER` | `INTEGER` | `INT` | no |  | primary key |  |\n" +
+			"| `user_id` | `int` | `INTEGER` | `INTEGER` | `INT` | yes |  | foreign key |  |\n\n" +
+			"## References\n\n- `user_id` references [`users`](users.md).`id`, ON DELETE CASCADE\n",
+	}
+
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, files[name], content)
+		}
+	}
+	if len(files) != len(want) {
+		t.Errorf("%d files, want %d", len(files), len(want))
+	}
+}
+
+func TestGenerateDocsHTML(t *testing.T) {
+	files, err := GenerateDocs(parseSchema(t, "sqlite", docsSchema), &Config{Format: "html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     string
+		contains string
+	}{
+		{"index.html", "<title>Schema</title>"},
+		{"index.html", `<td><a href="users.html"><code>users</code></a></td><td>2</td><td>People who can sign in.</td>`},
+		{"users.html", "<title>users</title>"},
+		{"users.html", "<p>People who can sign in.</p>\n<p>Rows are never deleted.</p>"},
+		{"users.html", "<td><code>a&lt;b&gt;</code></td>"},
+		{"users.html", "<td>Login | email</td>"},
+		{"posts.html", "<li><code>user_id</code> references <a href=\"users.html\"><code>users</code></a>.<code>id</code>, ON DELETE CASCADE</li>"},
+	}
+
+	for _, test := range tests {
+		if !strings.Contains(files[test.file], test.contains) {
+			t.Errorf("%s does not contain %q:\n%s", test.file, test.contains, files[test.file])
+		}
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		table  string
+		colmun string
+	}{
+		{"table", "/// the users\ntable users\n\tid int\nend\n", "the users", ""},
+		{"paragraphs", "/// first\n///\n/// second\ntable users\n\tid int\nend\n", "first\n\nsecond", ""},
+		{"colmun", "table users\n\t/// the key\n\tid int\nend\n", "", "the key"},
+		{"no doc", "table users\n\tid int\nend\n", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed := parseSchema(t, "sqlite", test.schema)
+			table := parsed.Tables[0]
+			if table.Doc != test.table || table.Colmuns[0].Doc != test.colmun {
+				t.Errorf("docs %q and %q, want %q and %q", table.Doc, table.Colmuns[0].Doc, test.table, test.colmun)
+			}
+		})
+	}
+}
+
+// fmt keeps doc comments where they are
+func TestFormatDocComments(t *testing.T) {
+	schema := "set provider sqlite\n\n" + docsSchema
+
+	formatted := Format(parseSchema(t, "sqlite", docsSchema))
+	if formatted != schema {
+		t.Errorf("got:\n%s\nwant:\n%s", formatted, schema)
+	}
+
+	reparsed, err := Parse(NewTokenizer(formatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again := Format(reparsed); again != formatted {
+		t.Errorf("formatting is not stable:\n%s", again)
+	}
+}
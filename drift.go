@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Drift compares a sqlite schema with the ast introspected from its database
+// and describes every difference, in the order of the schema tables
+func Drift(schema *AST, database *AST) ([]string, error) {
+	initValues()
+	provider = sqlite
+
+	issues := []string{}
+
+	for _, table := range schema.Tables {
+		dbTable := findTable(database, table.Name)
+		if dbTable == nil {
+			issues = append(issues, fmt.Sprintf("table %s: missing from the database", table.Name))
+			continue
+		}
+
+		tableIssues, err := driftTable(table, dbTable)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, tableIssues...)
+	}
+
+	for _, dbTable := range database.Tables {
+		if findTable(schema, dbTable.Name) == nil {
+			issues = append(issues, fmt.Sprintf("table %s: not in the schema", dbTable.Name))
+		}
+	}
+
+	return issues, nil
+}
+
+func driftTable(table *TabelAST, dbTable *TabelAST) ([]string, error) {
+	issues := []string{}
+
+	for _, colmun := range table.Colmuns {
+		name := table.Name + "." + colmun.Name
+		dbColmun := findColmun(dbTable, colmun.Name)
+		if dbColmun == nil {
+			issues = append(issues, fmt.Sprintf("colmun %s: missing from the database", name))
+			continue
+		}
+
+		props, err := driftProperties(colmun)
+		if err != nil {
+			return nil, err
+		}
+		dbProps, err := driftProperties(dbColmun)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := []string{}
+		for key := range props {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if !strings.EqualFold(props[key], dbProps[key]) {
+				issues = append(issues, fmt.Sprintf(
+					"colmun %s: %s is %s in the database, %s in the schema",
+					name,
+					key,
+					dbProps[key],
+					props[key],
+				))
+			}
+		}
+	}
+
+	for _, dbColmun := range dbTable.Colmuns {
+		if findColmun(table, dbColmun.Name) == nil {
+			issues = append(issues, fmt.Sprintf("colmun %s.%s: not in the schema", table.Name, dbColmun.Name))
+		}
+	}
+
+	for _, ref := range table.References {
+		name := fmt.Sprintf("foreign key %s.%s", table.Name, ref.SourceCol)
+		dbRef := findReference(dbTable, ref.SourceCol)
+		if dbRef == nil {
+			issues = append(issues, fmt.Sprintf("%s: missing from the database", name))
+			continue
+		}
+
+		if referenceTarget(ref) != referenceTarget(dbRef) {
+			issues = append(issues, fmt.Sprintf(
+				"%s: is %s in the database, %s in the schema",
+				name,
+				referenceTarget(dbRef),
+				referenceTarget(ref),
+			))
+		}
+	}
+
+	for _, dbRef := range dbTable.References {
+		if findReference(table, dbRef.SourceCol) == nil {
+			issues = append(issues, fmt.Sprintf("foreign key %s.%s: not in the schema", table.Name, dbRef.SourceCol))
+		}
+	}
+
+	return issues, nil
+}
+
+// driftProperties gives what sqlite keeps of a colmun definition, as
+// comparable strings
+func driftProperties(colmun *ColmunAST) (map[string]string, error) {
+	sqlType, err := getType(colmun)
+	if err != nil {
+		return nil, err
+	}
+
+	yesNo := func(attr string) string {
+		if _, exists := (*colmun.Attributes)[attr]; exists {
+			return "yes"
+		}
+		return "no"
+	}
+
+	defaultValue := strings.TrimPrefix(attributeSQL(colmun, "default"), "DEFAULT ")
+	if len(defaultValue) == 0 {
+		defaultValue = "none"
+	}
+
+	// sqlite stores '0' as 0 in numeric colmuns and 0 as '0' in text ones,
+	// quotes around a number make no difference
+	if unquoted, found := strings.CutPrefix(defaultValue, "'"); found && strings.HasSuffix(unquoted, "'") {
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(unquoted, "'"), 64); err == nil {
+			defaultValue = strings.TrimSuffix(unquoted, "'")
+		}
+	}
+
+	return map[string]string{
+		"type":           strings.Join(strings.Fields(sqlType), " "),
+		"nullable":       yesNo("nullable"),
+		"default":        defaultValue,
+		"primary key":    yesNo("id"),
+		"unique":         yesNo("unique"),
+		"auto increment": yesNo("auto_increment"),
+	}, nil
+}
+
+// referenceTarget writes a reference the same way whether NO ACTION was
+// declared or left out
+func referenceTarget(ref *ReferenceAST) string {
+	normalized := *ref
+	normalized.OnDelete = sqliteAction(strings.ToUpper(ref.OnDelete))
+	normalized.OnUpdate = sqliteAction(strings.ToUpper(ref.OnUpdate))
+
+	target := fmt.Sprintf("%s(%s)", ref.TargetTable, ref.TargetCol)
+	if actions := referenceActions(&normalized); len(actions) > 0 {
+		target += " " + actions
+	}
+	return target
+}
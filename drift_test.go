@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	schema := `
+table users
+	id int @id
+	email string @unique
+	age int @nullable @default("0")
+end
+table posts
+	id int @id
+	author int @reference("users", "id") @onDelete("CASCADE")
+end
+`
+	users := "CREATE TABLE users (id INTEGER PRIMARY KEY NOT NULL, email TEXT UNIQUE NOT NULL, age INTEGER DEFAULT 0)"
+	posts := "CREATE TABLE posts (id INTEGER PRIMARY KEY NOT NULL, author INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE)"
+
+	tests := []struct {
+		name       string
+		statements []string
+		issues     []string
+	}{
+		{
+			"matching",
+			[]string{users, posts},
+			[]string{},
+		},
+		{
+			"no action written out",
+			[]string{users, "CREATE TABLE posts (id INTEGER PRIMARY KEY NOT NULL, author INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE ON UPDATE NO ACTION)"},
+			[]string{},
+		},
+		{
+			"missing and extra tables",
+			[]string{users, "CREATE TABLE logs (id INTEGER)"},
+			[]string{"table posts: missing from the database", "table logs: not in the schema"},
+		},
+		{
+			"colmuns",
+			[]string{
+				"CREATE TABLE users (id INTEGER PRIMARY KEY NOT NULL, email TEXT NOT NULL, nickname TEXT)",
+				posts,
+			},
+			[]string{
+				"colmun users.email: unique is no in the database, yes in the schema",
+				"colmun users.age: missing from the database",
+				"colmun users.nickname: not in the schema",
+			},
+		},
+		{
+			"colmun properties",
+			[]string{
+				"CREATE TABLE users (id INTEGER PRIMARY KEY NOT NULL, email TEXT UNIQUE, age TEXT NOT NULL DEFAULT 1)",
+				posts,
+			},
+			[]string{
+				"colmun users.email: nullable is yes in the database, no in the schema",
+				"colmun users.age: default is 1 in the database, 0 in the schema",
+				"colmun users.age: nullable is no in the database, yes in the schema",
+				"colmun users.age: type is TEXT in the database, INTEGER in the schema",
+			},
+		},
+		{
+			"foreign keys",
+			[]string{
+				users,
+				"CREATE TABLE posts (id INTEGER PRIMARY KEY NOT NULL REFERENCES users (id), author INTEGER NOT NULL REFERENCES users (id))",
+			},
+			[]string{
+				"foreign key posts.author: is users(id) in the database, users(id) ON DELETE CASCADE in the schema",
+				"foreign key posts.id: not in the schema",
+			},
+		},
+		{
+			"missing foreign key",
+			[]string{users, "CREATE TABLE posts (id INTEGER PRIMARY KEY NOT NULL, author INTEGER NOT NULL)"},
+			[]string{"foreign key posts.author: missing from the database"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, url := createDatabase(t, test.statements...)
+			database, _, err := IntrospectSQLite(db, url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issues, err := Drift(parseSchema(t, "sqlite", schema), database)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("issues %q, want %q", issues, test.issues)
+			}
+		})
+	}
+}
+
+func TestRunDrift(t *testing.T) {
+	_, url := createDatabase(t, "CREATE TABLE users (id INTEGER PRIMARY KEY NOT NULL)")
+
+	tests := []struct {
+		name   string
+		schema string
+		code   int
+	}{
+		{"matching", "table users\n\tid int @id\nend\n", exitOK},
+		{"drift", "table users\n\tid int @id\n\tname string\nend\n", exitSchemaError},
+		{"other provider", "set provider mysql\ntable users\n\tid int @id\nend\n", exitUsageError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeSchema(t, t.TempDir(), "schema.sqmi", test.schema)
+
+			code := exitOK
+			withStdio(t, "", func() {
+				code = Run([]string{"drift", "--url", url, path})
+			})
+			if code != test.code {
+				t.Errorf("exit code %d, want %d", code, test.code)
+			}
+		})
+	}
+}
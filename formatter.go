@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format writes an ast back as schema source in the canonical layout: one
+// blank line between blocks, colmun names and types aligned and attributes in
+// a fixed order. Only the first file of the ast is written, what it imports
+// stays in the imported files
+func Format(ast *AST) string {
+	root := ""
+	if len(ast.Files) > 0 {
+		root = ast.Files[0]
+	}
+
+	blocks := []string{}
+
+	header := strings.Builder{}
+	for _, setting := range ast.Settings {
+		if setting.Pos.File == root {
+			header.WriteString(fmt.Sprintf("set %s %s\n", setting.Name, formatSetValue(setting.Value)))
+		}
+	}
+	if header.Len() > 0 {
+		blocks = append(blocks, header.String())
+	}
+
+	imports := strings.Builder{}
+	for _, imp := range ast.Imports {
+		if imp.Pos.File == root {
+			imports.WriteString(fmt.Sprintf("import \"%s\"\n", imp.Path))
+		}
+	}
+	if imports.Len() > 0 {
+		blocks = append(blocks, imports.String())
+	}
+
+	for _, table := range ast.Tables {
+		if table.Pos.File == root {
+			blocks = append(blocks, formatTable(table))
+		}
+	}
+
+	return strings.Join(blocks, "\n")
+}
+
+func formatSetValue(value string) string {
+	if isIden(value) {
+		return value
+	}
+	return fmt.Sprintf("\"%s\"", value)
+}
+
+func isIden(value string) bool {
+	if len(value) == 0 || !isLetter(value[0]) {
+		return false
+	}
+	for i := 1; i < len(value); i++ {
+		if !isLetter(value[i]) && !isNumber(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTable(table *TabelAST) string {
+	nameWidth := 0
+	typeWidth := 0
+	colTypes := make([]string, len(table.Colmuns))
+	for i, colmun := range table.Colmuns {
+		colTypes[i] = formatColmunType(colmun)
+		if len(colmun.Name) > nameWidth {
+			nameWidth = len(colmun.Name)
+		}
+		if len(colTypes[i]) > typeWidth {
+			typeWidth = len(colTypes[i])
+		}
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(formatDoc(table.Doc, ""))
+	builder.WriteString("table " + table.Name)
+	for _, attr := range sortAttributes(table.Attributes) {
+		builder.WriteString(" " + formatAttribute(attr.Name, attr.Values))
+	}
+	builder.WriteString("\n")
+
+	for i, colmun := range table.Colmuns {
+		builder.WriteString(formatDoc(colmun.Doc, "\t"))
+
+		attrs := formatColmunAttributes(colmun, table)
+		if len(attrs) == 0 {
+			builder.WriteString(fmt.Sprintf("\t%-*s %s\n", nameWidth, colmun.Name, colTypes[i]))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf(
+			"\t%-*s %-*s %s\n",
+			nameWidth,
+			colmun.Name,
+			typeWidth,
+			colTypes[i],
+			attrs,
+		))
+	}
+
+	builder.WriteString("end\n")
+	return builder.String()
+}
+
+func formatDoc(doc string, indent string) string {
+	if len(doc) == 0 {
+		return ""
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(doc, "\n") {
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%s/// %s", indent, line), " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func formatColmunType(colmun *ColmunAST) string {
+	if colmun.Data_type == "raw" {
+		attr, exists := (*colmun.Attributes)["raw"]
+		if exists && len(attr.Values) == 1 {
+			return fmt.Sprintf("`%s`", attr.Values[0].Value)
+		}
+	}
+
+	if len(colmun.Type_params) > 0 {
+		return fmt.Sprintf("%s(%s)", colmun.Data_type, strings.Join(colmun.Type_params, ", "))
+	}
+
+	return colmun.Data_type
+}
+
+func formatColmunAttributes(colmun *ColmunAST, table *TabelAST) string {
+	attrs := []string{}
+
+	for _, attr := range sortAttributes(colmun.Attributes) {
+		if attr.Name == "raw" {
+			continue
+		}
+		attrs = append(attrs, formatAttribute(attr.Name, attr.Values))
+	}
+
+	// references live on the table but are declared on the colmun
+	for _, ref := range table.References {
+		if ref.SourceCol != colmun.Name {
+			continue
+		}
+
+		attrs = append(attrs, fmt.Sprintf("@reference(\"%s\", \"%s\")", ref.TargetTable, ref.TargetCol))
+		if len(ref.OnDelete) > 0 {
+			attrs = append(attrs, fmt.Sprintf("@onDelete(\"%s\")", ref.OnDelete))
+		}
+		if len(ref.OnUpdate) > 0 {
+			attrs = append(attrs, fmt.Sprintf("@onUpdate(\"%s\")", ref.OnUpdate))
+		}
+	}
+
+	return strings.Join(attrs, " ")
+}
+
+func formatAttribute(name string, args []*AttributeArgAST) string {
+	if len(args) == 0 {
+		return "@" + name
+	}
+
+	values := []string{}
+	for _, arg := range args {
+		values = append(values, formatAttributeArg(arg))
+	}
+
+	return fmt.Sprintf("@%s(%s)", name, strings.Join(values, ", "))
+}
+
+func formatAttributeArg(arg *AttributeArgAST) string {
+	if arg.Type == "raw" {
+		return fmt.Sprintf("`%s`", arg.Value)
+	}
+	return fmt.Sprintf("\"%s\"", arg.Value)
+}
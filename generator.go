@@ -4,53 +4,186 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type Provider string
 type Type map[Provider]string
 
+// a logical type that takes between Min and Max parameters, like string(255),
+// every %s in the provider type is replaced by the parameters
+type ParamType struct {
+	Min   int
+	Max   int
+	Types Type
+}
+
 const (
-	sqlite Provider = "sqlite"
+	sqlite     Provider = "sqlite"
+	postgresql Provider = "postgresql"
+	mysql      Provider = "mysql"
 )
 
+var providers = []Provider{sqlite, postgresql, mysql}
+
+// a generate target besides sql. Targets writing a single file have
+// Generate, targets writing several files into a directory have Files
+type Target struct {
+	DefaultOutput string
+	Generate      func(ast *AST, cfg *Config) (string, error)
+	Files         func(ast *AST, cfg *Config) (map[string]string, error)
+}
+
+var targets = map[string]*Target{
+	"go":         {"schema.go", GenerateGo, nil},
+	"typescript": {"schema.ts", GenerateTypeScript, nil},
+	"docs":       {"docs", nil, GenerateDocs},
+	"jsonschema": {"schema.json", GenerateJSONSchema, nil},
+	"dbml":       {"schema.dbml", GenerateDBML, nil},
+}
+
 var provider Provider = sqlite
 var types map[string]Type
+var paramTypes map[string]ParamType
 var attrFuncMap map[string]func(attr *AttributeAST) (string, error)
 
+// order in which colmun attributes are written, attributes not listed here
+// come after in alphabetical order
+var attrOrder = []string{"id", "auto_increment", "default", "nullable"}
+
 func initValues() {
 	INT := Type{
-		sqlite: "INTEGER",
+		sqlite:     "INTEGER",
+		postgresql: "INTEGER",
+		mysql:      "INT",
 	}
 
 	STRING := Type{
-		sqlite: "TEXT",
+		sqlite:     "TEXT",
+		postgresql: "TEXT",
+		mysql:      "VARCHAR(255)",
 	}
 
 	BOOL := Type{
-		sqlite: "NUMERIC",
+		sqlite:     "NUMERIC",
+		postgresql: "BOOLEAN",
+		mysql:      "BOOLEAN",
 	}
 
 	DATETIME := Type{
-		sqlite: "NUMERIC",
+		sqlite:     "NUMERIC",
+		postgresql: "TIMESTAMP",
+		mysql:      "DATETIME",
 	}
 
 	FLOAT := Type{
-		sqlite: "REAL",
+		sqlite:     "REAL",
+		postgresql: "DOUBLE PRECISION",
+		mysql:      "DOUBLE",
 	}
 
 	BLOB := Type{
-		sqlite: "BLOB",
+		sqlite:     "BLOB",
+		postgresql: "BYTEA",
+		mysql:      "BLOB",
+	}
+
+	BIGINT := Type{
+		sqlite:     "INTEGER",
+		postgresql: "BIGINT",
+		mysql:      "BIGINT",
+	}
+
+	SMALLINT := Type{
+		sqlite:     "INTEGER",
+		postgresql: "SMALLINT",
+		mysql:      "SMALLINT",
+	}
+
+	DECIMAL := Type{
+		sqlite:     "NUMERIC",
+		postgresql: "NUMERIC",
+		mysql:      "DECIMAL(65,30)",
+	}
+
+	DATE := Type{
+		sqlite:     "NUMERIC",
+		postgresql: "DATE",
+		mysql:      "DATE",
+	}
+
+	TIME := Type{
+		sqlite:     "NUMERIC",
+		postgresql: "TIME",
+		mysql:      "TIME",
+	}
+
+	TIMESTAMPTZ := Type{
+		sqlite:     "NUMERIC",
+		postgresql: "TIMESTAMPTZ",
+		mysql:      "TIMESTAMP",
+	}
+
+	UUID := Type{
+		sqlite:     "TEXT",
+		postgresql: "UUID",
+		mysql:      "CHAR(36)",
+	}
+
+	JSON := Type{
+		sqlite:     "TEXT",
+		postgresql: "JSON",
+		mysql:      "JSON",
+	}
+
+	TEXT := Type{
+		sqlite:     "TEXT",
+		postgresql: "TEXT",
+		mysql:      "TEXT",
 	}
 
 	types = map[string]Type{
-		"int":      INT,
-		"string":   STRING,
-		"boolean":  BOOL,
-		"bool":     BOOL,
-		"datetime": DATETIME,
-		"float":    FLOAT,
-		"blob":     BLOB,
+		"int":         INT,
+		"string":      STRING,
+		"boolean":     BOOL,
+		"bool":        BOOL,
+		"datetime":    DATETIME,
+		"float":       FLOAT,
+		"blob":        BLOB,
+		"bytes":       BLOB,
+		"bigint":      BIGINT,
+		"smallint":    SMALLINT,
+		"decimal":     DECIMAL,
+		"date":        DATE,
+		"time":        TIME,
+		"timestamptz": TIMESTAMPTZ,
+		"uuid":        UUID,
+		"json":        JSON,
+		"text":        TEXT,
+	}
+
+	paramTypes = map[string]ParamType{
+		"string": {1, 1, Type{
+			sqlite:     "TEXT",
+			postgresql: "VARCHAR(%s)",
+			mysql:      "VARCHAR(%s)",
+		}},
+		"char": {1, 1, Type{
+			sqlite:     "TEXT",
+			postgresql: "CHAR(%s)",
+			mysql:      "CHAR(%s)",
+		}},
+		"decimal": {1, 2, Type{
+			sqlite:     "NUMERIC",
+			postgresql: "NUMERIC(%s)",
+			mysql:      "DECIMAL(%s)",
+		}},
+		"bytes": {1, 1, Type{
+			sqlite:     "BLOB",
+			postgresql: "BYTEA",
+			mysql:      "VARBINARY(%s)",
+		}},
 	}
 
 	attrFuncMap = map[string]func(*AttributeAST) (string, error){
@@ -58,23 +191,35 @@ func initValues() {
 		"default":        handleDefaultAttr,
 		"auto_increment": handleAutoIncrementAttr,
 		"nullable":       handleNullableAttr,
+		"unique":         handleUniqueAttr,
 	}
 }
 
 func GenerateSQL(ast *AST) (string, error) {
+	return GenerateSQLForProvider(ast, ast.Configuration["provider"])
+}
+
+// GenerateSQLForProvider generates the sql for the given provider instead of
+// the one declared with 'set provider', the ast is left untouched so it can be
+// reused for several providers
+func GenerateSQLForProvider(ast *AST, target string) (string, error) {
 
 	initValues()
 
-	if !isProviderAvailable(ast.Configuration["provider"]) {
+	if !isProviderAvailable(target) {
 		return "", errors.New("Error: Provider not supported")
 	}
+	provider = Provider(target)
 
 	if len(ast.Tables) == 0 {
 		return "", errors.New("Error: No tables declared")
 	}
 
+	// referenced tables are created first, they may be declared later
+	tables, _ := sortTablesByReferences(ast)
+
 	builder := strings.Builder{}
-	for _, table := range ast.Tables {
+	for _, table := range tables {
 		sqlStr, err := generateTableSQL(table)
 		if err != nil {
 			return "", err
@@ -85,6 +230,42 @@ func GenerateSQL(ast *AST) (string, error) {
 	return builder.String(), nil
 }
 
+// sortTablesByReferences puts referenced tables first, as a table can only
+// reference the tables created before it. Tables referencing each other
+// keep their declared order as far as possible and are reported, references
+// to tables missing from the ast are left out
+func sortTablesByReferences(ast *AST) ([]*TabelAST, []string) {
+	sorted := []*TabelAST{}
+	warnings := []string{}
+	state := map[string]int{} // 1 visiting, 2 done
+
+	var visit func(table *TabelAST)
+	visit = func(table *TabelAST) {
+		if state[table.Name] == 2 {
+			return
+		}
+		if state[table.Name] == 1 {
+			warnings = append(warnings, fmt.Sprintf("Tables referencing each other around '%s' can not be ordered", table.Name))
+			return
+		}
+
+		state[table.Name] = 1
+		for _, ref := range table.References {
+			// a reference to the own table needs no ordering
+			if target := findTable(ast, ref.TargetTable); target != nil && target != table {
+				visit(target)
+			}
+		}
+		state[table.Name] = 2
+		sorted = append(sorted, table)
+	}
+
+	for _, table := range ast.Tables {
+		visit(table)
+	}
+	return sorted, warnings
+}
+
 func generateTableSQL(tableAST *TabelAST) (string, error) {
 	if !isValidTableName(tableAST.Name) {
 		return "", errors.New(fmt.Sprintf("Error: Bad name for table '%s'", tableAST.Name))
@@ -94,35 +275,68 @@ func generateTableSQL(tableAST *TabelAST) (string, error) {
 		return "", errors.New("Error: No Colmuns Specified for Table")
 	}
 
-	builder := strings.Builder{}
-	builder.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", tableAST.Name))
+	definitions := []string{}
 
 	for _, colmun := range tableAST.Colmuns {
 		colStr, err := handleColmun(colmun, tableAST.Name)
 		if err != nil {
 			return "", err
 		}
-		builder.WriteString("\t" + colStr + "\n")
+		definitions = append(definitions, colStr)
 	}
 
 	for _, ref := range tableAST.References {
-		builder.WriteString(handleRef(ref))
+		definitions = append(definitions, handleRef(ref))
 	}
 
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", quoteName(tableAST.Name)))
+	builder.WriteString("\t" + strings.Join(definitions, ",\n\t") + "\n")
 	builder.WriteString(");")
 
 	return builder.String(), nil
 }
 
+// words reserved by at least one provider, names like these are quoted so a
+// table can still be called user or order
+var reservedWords = map[string]bool{
+	"all": true, "and": true, "as": true, "asc": true, "between": true,
+	"by": true, "case": true, "check": true, "column": true, "constraint": true,
+	"create": true, "current_date": true, "current_time": true,
+	"current_timestamp": true, "current_user": true, "default": true,
+	"delete": true, "desc": true, "distinct": true, "drop": true, "else": true,
+	"end": true, "except": true, "exists": true, "foreign": true, "from": true,
+	"grant": true, "group": true, "having": true, "in": true, "index": true,
+	"insert": true, "intersect": true, "into": true, "is": true, "join": true,
+	"key": true, "left": true, "like": true, "limit": true, "not": true,
+	"null": true, "offset": true, "on": true, "or": true, "order": true,
+	"primary": true, "references": true, "right": true, "select": true,
+	"session_user": true, "table": true, "then": true, "to": true,
+	"union": true, "unique": true, "update": true, "user": true, "using": true,
+	"values": true, "when": true, "where": true, "with": true,
+}
+
+// quoteName quotes a table or colmun name the way the provider quotes
+// identifiers when it is a reserved word, other names are written as is
+func quoteName(name string) string {
+	if !reservedWords[strings.ToLower(name)] {
+		return name
+	}
+	if provider == mysql {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
 func handleRef(ref *ReferenceAST) string {
 	builder := strings.Builder{}
 
 	builder.WriteString(
 		fmt.Sprintf(
-			"\tFOREIGN KEY (%s) REFERENCES %s(%s)",
-			ref.SourceCol,
-			ref.TargetTable,
-			ref.TargetCol,
+			"FOREIGN KEY (%s) REFERENCES %s(%s)",
+			quoteName(ref.SourceCol),
+			quoteName(ref.TargetTable),
+			quoteName(ref.TargetCol),
 		),
 	)
 
@@ -134,8 +348,6 @@ func handleRef(ref *ReferenceAST) string {
 		builder.WriteString(fmt.Sprintf(" ON UPDATE %s", ref.OnUpdate))
 	}
 
-	builder.WriteString(",\n")
-
 	return builder.String()
 }
 
@@ -156,31 +368,33 @@ func handleColmun(
 	}
 
 	builder := &strings.Builder{}
-	builder.WriteString(fmt.Sprintf("%s %s", colmun.Name, colmunType))
+	builder.WriteString(fmt.Sprintf("%s %s", quoteName(colmun.Name), colmunType))
 
 	hasNullableAttr := false
-	for _, attr := range *colmun.Attributes {
+	for _, attr := range sortAttributes(colmun.Attributes) {
 		if attr.Name == "nullable" {
 			hasNullableAttr = true
 		}
 
-		str, err := handleAttr(attr)
+		str, err := handleAttr(boolDefault(colmun, attr))
 		if err != nil {
 			return "", err
 		}
-		builder.WriteString(" " + str)
+		if len(str) > 0 {
+			builder.WriteString(" " + str)
+		}
 	}
 
 	if !hasNullableAttr {
 		builder.WriteString(" NOT NULL")
 	}
 
-	builder.WriteString(",")
 	return builder.String(), nil
 }
 
 func handleAttr(attr *AttributeAST) (string, error) {
-	if attr.Name == "raw" {
+	// rename hints only matter to migrations
+	if attr.Name == "raw" || attr.Name == "renamedFrom" || strings.HasPrefix(attr.Name, "db.") {
 		return "", nil
 	}
 
@@ -194,11 +408,56 @@ func handleAttr(attr *AttributeAST) (string, error) {
 	return sqlStr, err
 }
 
+// boolDefault writes the default of a bool colmun the way the provider writes
+// booleans, quoted it would be stored as text
+func boolDefault(colmun *ColmunAST, attr *AttributeAST) *AttributeAST {
+	if attr.Name != "default" || (colmun.Data_type != "bool" && colmun.Data_type != "boolean") {
+		return attr
+	}
+	if len(attr.Values) != 1 || attr.Values[0].Type != "string" {
+		return attr
+	}
+
+	value := attr.Values[0].Value == "true" || attr.Values[0].Value == "1"
+	literal := map[bool]string{true: "1", false: "0"}[value]
+	if provider == postgresql {
+		literal = map[bool]string{true: "TRUE", false: "FALSE"}[value]
+	}
+
+	return &AttributeAST{attr.Name, []*AttributeArgAST{{literal, "raw", attr.Values[0].Pos}}, attr.Pos}
+}
+
+func sortAttributes(attributes *AttributesAST) []*AttributeAST {
+	rank := func(name string) int {
+		for i, n := range attrOrder {
+			if n == name {
+				return i
+			}
+		}
+		return len(attrOrder)
+	}
+
+	sorted := []*AttributeAST{}
+	for _, attr := range *attributes {
+		sorted = append(sorted, attr)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		ri, rj := rank(sorted[i].Name), rank(sorted[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
 func handleIdAttr(attr *AttributeAST) (string, error) {
 	if len(attr.Values) != 0 {
 		return "", errors.New("Error: id takes no parameters")
 	}
-	return "PRIMARY KEY UNIQUE", nil
+	return "PRIMARY KEY", nil
 }
 
 func handleDefaultAttr(attr *AttributeAST) (string, error) {
@@ -221,13 +480,23 @@ func handleAutoIncrementAttr(attr *AttributeAST) (string, error) {
 		return "", errors.New("Error: auto_increment takes no parameters")
 	}
 
-	if ast.Configuration["provider"] == "sqlite" {
+	switch provider {
+	case sqlite:
 		return "AUTOINCREMENT", nil
+	case postgresql:
+		return "GENERATED BY DEFAULT AS IDENTITY", nil
 	}
 
 	return "AUTO_INCREMENT", nil
 }
 
+func handleUniqueAttr(attr *AttributeAST) (string, error) {
+	if len(attr.Values) > 0 {
+		return "", errors.New("Error: unique takes no parameters")
+	}
+	return "UNIQUE", nil
+}
+
 func handleNullableAttr(attr *AttributeAST) (string, error) {
 	if len(attr.Values) > 0 {
 		return "", errors.New("Error: nullable takes no parameters")
@@ -238,6 +507,14 @@ func handleNullableAttr(attr *AttributeAST) (string, error) {
 func getType(colmun *ColmunAST) (string, error) {
 	var colmunDataTypeRes string
 
+	override, exists := (*colmun.Attributes)["db."+string(provider)]
+	if exists {
+		if len(override.Values) != 1 {
+			return "", fmt.Errorf("Error: %s takes one parameter", override.Name)
+		}
+		return override.Values[0].Value, nil
+	}
+
 	if colmun.Data_type == "raw" {
 		attr, exists := (*colmun.Attributes)["raw"]
 		if !exists {
@@ -249,6 +526,25 @@ func getType(colmun *ColmunAST) (string, error) {
 		}
 
 		colmunDataTypeRes = attr.Values[0].Value
+	} else if len(colmun.Type_params) > 0 {
+		paramType, exists := paramTypes[colmun.Data_type]
+		if !exists {
+			return "", errors.New(
+				fmt.Sprintf("Error: Data type '%s' takes no parameters", colmun.Data_type),
+			)
+		}
+
+		if len(colmun.Type_params) < paramType.Min || len(colmun.Type_params) > paramType.Max {
+			return "", errors.New(
+				fmt.Sprintf("Error: Wrong number of parameters for data type '%s'", colmun.Data_type),
+			)
+		}
+
+		colmunDataTypeRes = strings.ReplaceAll(
+			paramType.Types[provider],
+			"%s",
+			strings.Join(colmun.Type_params, ","),
+		)
 	} else {
 		colmunDataType, exists := types[colmun.Data_type][provider]
 		if !exists {
@@ -260,10 +556,12 @@ func getType(colmun *ColmunAST) (string, error) {
 }
 
 func isProviderAvailable(provider string) bool {
-	if provider != string(sqlite) {
-		return false
+	for _, p := range providers {
+		if string(p) == provider {
+			return true
+		}
 	}
-	return true
+	return false
 }
 
 func isValidTableName(tableName string) bool {
@@ -275,5 +573,5 @@ func isValidTableName(tableName string) bool {
 }
 
 func isValidColmunName(colName string) bool {
-	return true
+	return isValidTableName(colName)
 }
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortTablesByReferences(t *testing.T) {
+	table := func(name string, targets ...string) *TabelAST {
+		refs := []*ReferenceAST{}
+		for _, target := range targets {
+			refs = append(refs, &ReferenceAST{target, "id", target + "_id", "", ""})
+		}
+		return &TabelAST{Name: name, References: refs}
+	}
+
+	tests := []struct {
+		name     string
+		tables   []*TabelAST
+		sorted   []string
+		warnings int
+	}{
+		{
+			"declared order is kept",
+			[]*TabelAST{table("users"), table("posts", "users")},
+			[]string{"users", "posts"},
+			0,
+		},
+		{
+			"referenced tables first",
+			[]*TabelAST{table("comments", "posts", "users"), table("posts", "users"), table("users")},
+			[]string{"users", "posts", "comments"},
+			0,
+		},
+		{
+			"unknown target",
+			[]*TabelAST{table("posts", "users"), table("tags")},
+			[]string{"posts", "tags"},
+			0,
+		},
+		{
+			"table referencing itself",
+			[]*TabelAST{table("employees", "employees", "teams"), table("teams")},
+			[]string{"teams", "employees"},
+			0,
+		},
+		{
+			"tables referencing each other",
+			[]*TabelAST{table("a", "b"), table("b", "a")},
+			[]string{"b", "a"},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorted, warnings := sortTablesByReferences(&AST{Tables: test.tables})
+
+			names := []string{}
+			for _, table := range sorted {
+				names = append(names, table.Name)
+			}
+			if !reflect.DeepEqual(names, test.sorted) {
+				t.Errorf("sorted %q, want %q", names, test.sorted)
+			}
+			if len(warnings) != test.warnings {
+				t.Errorf("warnings %q, want %d", warnings, test.warnings)
+			}
+		})
+	}
+}
+
+// colmunSQL gives the definition generated for a single colmun
+func colmunSQL(t *testing.T, provider string, colmun string) string {
+	t.Helper()
+
+	sql, err := GenerateSQL(parseSchema(t, provider, "table t\n\t"+colmun+"\nend\n"))
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	lines := strings.Split(sql, "\n")
+	if len(lines) < 2 {
+		t.Fatalf("no colmun in:\n%s", sql)
+	}
+	return strings.TrimSpace(lines[1])
+}
+
+func TestGenerateParameterizedTypes(t *testing.T) {
+	tests := []struct {
+		colmun     string
+		sqlite     string
+		postgresql string
+		mysql      string
+	}{
+		{"name string", "name TEXT NOT NULL", "name TEXT NOT NULL", "name VARCHAR(255) NOT NULL"},
+		{"name string(100)", "name TEXT NOT NULL", "name VARCHAR(100) NOT NULL", "name VARCHAR(100) NOT NULL"},
+		{"code char(2)", "code TEXT NOT NULL", "code CHAR(2) NOT NULL", "code CHAR(2) NOT NULL"},
+		{"price decimal(10,2)", "price NUMERIC NOT NULL", "price NUMERIC(10,2) NOT NULL", "price DECIMAL(10,2) NOT NULL"},
+		{"hash bytes(32)", "hash BLOB NOT NULL", "hash BYTEA NOT NULL", "hash VARBINARY(32) NOT NULL"},
+		{
+			"id int @id @auto_increment",
+			"id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL",
+			"id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY NOT NULL",
+			"id INT PRIMARY KEY AUTO_INCREMENT NOT NULL",
+		},
+	}
+
+	for _, test := range tests {
+		for provider, want := range map[string]string{"sqlite": test.sqlite, "postgresql": test.postgresql, "mysql": test.mysql} {
+			t.Run(provider+"/"+test.colmun, func(t *testing.T) {
+				if got := colmunSQL(t, provider, test.colmun); got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestCheckTypeParams(t *testing.T) {
+	tests := []struct {
+		colmun string
+		error  string
+	}{
+		{"a string(1,2)", "Data type 'string' takes 1 parameters, got 2"},
+		{"a int(5)", "Data type 'int' takes no parameters"},
+		{"a char", "Data type 'char' requires parameters"},
+		{"a decimal(1,2,3)", "Data type 'decimal' takes 1 to 2 parameters, got 3"},
+		{"a money", "Unknown data type 'money'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.colmun, func(t *testing.T) {
+			errs := checkSchema(t, "table t\n\t"+test.colmun+"\nend\n")
+			if len(errs) != 1 || !strings.Contains(errs[0], test.error) {
+				t.Errorf("errors %q, want one containing %q", errs, test.error)
+			}
+		})
+	}
+}
+
+func TestGenerateUnknownProvider(t *testing.T) {
+	_, err := GenerateSQLForProvider(parseSchema(t, "sqlite", "table t\n\tid int\nend\n"), "oracle")
+	if err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+}
+
+func TestGenerateQuotesReservedNames(t *testing.T) {
+	schema := `
+table user
+	id int @id
+end
+
+table order
+	id int @id
+	by int @reference("user", "id")
+	total int
+end
+`
+	tests := []struct {
+		provider string
+		want     []string
+	}{
+		{"sqlite", []string{`CREATE TABLE "user" (`, `CREATE TABLE "order" (`, `"by" INTEGER NOT NULL`, `FOREIGN KEY ("by") REFERENCES "user"(id)`, "\ttotal INTEGER"}},
+		{"postgresql", []string{`CREATE TABLE "user" (`, `FOREIGN KEY ("by") REFERENCES "user"(id)`}},
+		{"mysql", []string{"CREATE TABLE `user` (", "CREATE TABLE `order` (", "FOREIGN KEY (`by`) REFERENCES `user`(id)"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.provider, func(t *testing.T) {
+			sql, err := GenerateSQL(parseSchema(t, test.provider, schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(sql, want) {
+					t.Errorf("sql does not contain %q:\n%s", want, sql)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateTypes(t *testing.T) {
+	tests := []struct {
+		dataType   string
+		sqlite     string
+		postgresql string
+		mysql      string
+	}{
+		{"int", "INTEGER", "INTEGER", "INT"},
+		{"bigint", "INTEGER", "BIGINT", "BIGINT"},
+		{"smallint", "INTEGER", "SMALLINT", "SMALLINT"},
+		{"float", "REAL", "DOUBLE PRECISION", "DOUBLE"},
+		{"decimal", "NUMERIC", "NUMERIC", "DECIMAL(65,30)"},
+		{"bool", "NUMERIC", "BOOLEAN", "BOOLEAN"},
+		{"text", "TEXT", "TEXT", "TEXT"},
+		{"datetime", "NUMERIC", "TIMESTAMP", "DATETIME"},
+		{"date", "NUMERIC", "DATE", "DATE"},
+		{"time", "NUMERIC", "TIME", "TIME"},
+		{"timestamptz", "NUMERIC", "TIMESTAMPTZ", "TIMESTAMP"},
+		{"uuid", "TEXT", "UUID", "CHAR(36)"},
+		{"json", "TEXT", "JSON", "JSON"},
+		{"bytes", "BLOB", "BYTEA", "BLOB"},
+	}
+
+	for _, test := range tests {
+		for provider, want := range map[string]string{"sqlite": test.sqlite, "postgresql": test.postgresql, "mysql": test.mysql} {
+			t.Run(provider+"/"+test.dataType, func(t *testing.T) {
+				got := colmunSQL(t, provider, "a "+test.dataType)
+				if got != "a "+want+" NOT NULL" {
+					t.Errorf("got %q, want type %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerateTypeOverrides(t *testing.T) {
+	colmun := "data json @db.postgresql(`JSONB`) @db.mysql(`LONGTEXT`)"
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{"sqlite", "data TEXT NOT NULL"},
+		{"postgresql", "data JSONB NOT NULL"},
+		{"mysql", "data LONGTEXT NOT NULL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.provider, func(t *testing.T) {
+			if got := colmunSQL(t, test.provider, colmun); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// the override replaces parameterized types too
+	if got := colmunSQL(t, "postgresql", "name string(20) @db.postgresql(`CITEXT`)"); got != "name CITEXT NOT NULL" {
+		t.Errorf("got %q, want the override", got)
+	}
+}
+
+func TestParseTypeOverrideErrors(t *testing.T) {
+	tests := []struct {
+		colmun string
+		error  string
+	}{
+		{"a json @db.oracle(`CLOB`)", "Unknown provider 'oracle' in @db.oracle"},
+		{"a json @db.postgresql(`X`, `Y`)", "@db.postgresql takes one parameters"},
+		{"a json @db.postgresql(`X`) @db.postgresql(`Y`)", "@db.postgresql already declared"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.colmun, func(t *testing.T) {
+			_, err := Parse(NewTokenizer("table t\n\t" + test.colmun + "\nend\n"))
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
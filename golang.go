@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// a go type and the one used when the colmun is @nullable, slices are nil
+// when null so they stay the same. Raw sql types hold whatever the driver
+// scans
+type GoType struct {
+	Type     string
+	Nullable string
+	Import   string
+}
+
+var goTypes = map[string]GoType{
+	"int":         {"int64", "sql.NullInt64", ""},
+	"bigint":      {"int64", "sql.NullInt64", ""},
+	"smallint":    {"int16", "sql.NullInt16", ""},
+	"string":      {"string", "sql.NullString", ""},
+	"char":        {"string", "sql.NullString", ""},
+	"text":        {"string", "sql.NullString", ""},
+	"uuid":        {"string", "sql.NullString", ""},
+	"decimal":     {"string", "sql.NullString", ""},
+	"bool":        {"bool", "sql.NullBool", ""},
+	"boolean":     {"bool", "sql.NullBool", ""},
+	"float":       {"float64", "sql.NullFloat64", ""},
+	"datetime":    {"time.Time", "sql.NullTime", "time"},
+	"date":        {"time.Time", "sql.NullTime", "time"},
+	"time":        {"time.Time", "sql.NullTime", "time"},
+	"timestamptz": {"time.Time", "sql.NullTime", "time"},
+	"blob":        {"[]byte", "[]byte", ""},
+	"bytes":       {"[]byte", "[]byte", ""},
+	"json":        {"json.RawMessage", "json.RawMessage", "encoding/json"},
+	"raw":         {"any", "any", ""},
+}
+
+// parts of names written in capitals, as golint wants them
+var goInitialisms = map[string]bool{
+	"id":   true,
+	"url":  true,
+	"uuid": true,
+	"json": true,
+	"api":  true,
+	"http": true,
+	"ip":   true,
+	"sql":  true,
+}
+
+// GenerateGo writes one struct per table with db and json tags, the output
+// is gofmt'ed
+func GenerateGo(ast *AST, cfg *Config) (string, error) {
+	imports := map[string]bool{}
+	structs := []string{}
+
+	for _, table := range ast.Tables {
+		fields := []string{}
+
+		for _, colmun := range table.Colmuns {
+			goType, exists := goTypes[colmun.Data_type]
+			if !exists {
+				return "", fmt.Errorf("Error: Type '%s' of colmun '%s' has no go type", colmun.Data_type, colmun.Name)
+			}
+
+			typeName := goType.Type
+			if _, nullable := (*colmun.Attributes)["nullable"]; nullable {
+				typeName = goType.Nullable
+				if cfg.Nullable == "pointer" && goType.Nullable != goType.Type {
+					typeName = "*" + goType.Type
+				}
+			}
+
+			if strings.HasPrefix(typeName, "sql.") {
+				imports["database/sql"] = true
+			}
+			if len(goType.Import) > 0 {
+				imports[goType.Import] = true
+			}
+
+			field := fmt.Sprintf(
+				"\t%s %s `db:\"%s\" json:\"%s\"`",
+				goName(colmun.Name),
+				typeName,
+				colmun.Name,
+				colmun.Name,
+			)
+			if colmun.Data_type == "raw" {
+				field += " // " + formatColmunType(colmun)
+			}
+			fields = append(fields, field)
+		}
+
+		structs = append(structs, fmt.Sprintf(
+			"// %s is a row of the table %s\ntype %s struct {\n%s\n}\n",
+			goName(table.Name),
+			table.Name,
+			goName(table.Name),
+			strings.Join(fields, "\n"),
+		))
+	}
+
+	src := "// Code generated by sql-mi. DO NOT EDIT.\n\npackage " + cfg.Package + "\n\n"
+
+	if len(imports) > 0 {
+		paths := []string{}
+		for path := range imports {
+			paths = append(paths, fmt.Sprintf("\t%q", path))
+		}
+		sort.Strings(paths)
+		src += "import (\n" + strings.Join(paths, "\n") + "\n)\n\n"
+	}
+
+	src += strings.Join(structs, "\n")
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return "", fmt.Errorf("Error: Generated go code is invalid: %v", err)
+	}
+	return string(formatted), nil
+}
+
+// goName turns a snake_case or camelCase name into an exported go name,
+// created_at becomes CreatedAt and user_id becomes UserID
+func goName(name string) string {
+	words := []string{}
+	word := ""
+	for i, ch := range name {
+		if ch == '_' {
+			words = append(words, word)
+			word = ""
+			continue
+		}
+		if i > 0 && ch >= 'A' && ch <= 'Z' && len(word) > 0 {
+			words = append(words, word)
+			word = ""
+		}
+		word += string(ch)
+	}
+	words = append(words, word)
+
+	result := ""
+	for _, word := range words {
+		if len(word) == 0 {
+			continue
+		}
+		if goInitialisms[strings.ToLower(word)] {
+			result += strings.ToUpper(word)
+		} else {
+			result += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	// a name like _1 would not start with a letter
+	if len(result) == 0 || !isLetter(result[0]) || result[0] == '_' {
+		result = "X" + result
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	schema := parseSchema(t, "postgresql", `
+table user_accounts
+	id int @id
+	email string(255) @unique
+	created_at datetime
+	bio text @nullable
+	avatar bytes @nullable
+	settings json
+	span `+"`INTERVAL`"+`
+	score float @nullable
+end
+`)
+
+	tests := []struct {
+		nullable string
+		want     string
+	}{
+		{"sql", `// Code generated by sql-mi. DO NOT EDIT.
+
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// UserAccounts is a row of the table user_accounts
+type UserAccounts struct {
+	ID        int64           ` + "`db:\"id\" json:\"id\"`" + `
+	Email     string          ` + "`db:\"email\" json:\"email\"`" + `
+	CreatedAt time.Time       ` + "`db:\"created_at\" json:\"created_at\"`" + `
+	Bio       sql.NullString  ` + "`db:\"bio\" json:\"bio\"`" + `
+	Avatar    []byte          ` + "`db:\"avatar\" json:\"avatar\"`" + `
+	Settings  json.RawMessage ` + "`db:\"settings\" json:\"settings\"`" + `
+	Span      any             ` + "`db:\"span\" json:\"span\"`" + ` // ` + "`INTERVAL`" + `
+	Score     sql.NullFloat64 ` + "`db:\"score\" json:\"score\"`" + `
+}
+`},
+		{"pointer", `// Code generated by sql-mi. DO NOT EDIT.
+
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserAccounts is a row of the table user_accounts
+type UserAccounts struct {
+	ID        int64           ` + "`db:\"id\" json:\"id\"`" + `
+	Email     string          ` + "`db:\"email\" json:\"email\"`" + `
+	CreatedAt time.Time       ` + "`db:\"created_at\" json:\"created_at\"`" + `
+	Bio       *string         ` + "`db:\"bio\" json:\"bio\"`" + `
+	Avatar    []byte          ` + "`db:\"avatar\" json:\"avatar\"`" + `
+	Settings  json.RawMessage ` + "`db:\"settings\" json:\"settings\"`" + `
+	Span      any             ` + "`db:\"span\" json:\"span\"`" + ` // ` + "`INTERVAL`" + `
+	Score     *float64        ` + "`db:\"score\" json:\"score\"`" + `
+}
+`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.nullable, func(t *testing.T) {
+			src, err := GenerateGo(schema, &Config{Package: "models", Nullable: test.nullable})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", src, test.want)
+			}
+		})
+	}
+}
+
+// every logical type has a go type
+func TestGoTypes(t *testing.T) {
+	initValues()
+
+	for _, name := range typeNames() {
+		if _, exists := goTypes[name]; !exists {
+			t.Errorf("type %q has no go type", name)
+		}
+	}
+}
+
+func TestGenerateGoWithoutImports(t *testing.T) {
+	schema := parseSchema(t, "sqlite", "table tags\n\tname string\nend\n")
+
+	src, err := GenerateGo(schema, &Config{Package: "db", Nullable: "sql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(src, "import") || !strings.Contains(src, "package db\n") {
+		t.Errorf("unexpected output:\n%s", src)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users", "Users"},
+		{"created_at", "CreatedAt"},
+		{"user_id", "UserID"},
+		{"createdAt", "CreatedAt"},
+		{"userId", "UserID"},
+		{"api_url", "APIURL"},
+		{"HTTPStatus", "HTTPStatus"},
+		{"_1", "X1"},
+		{"2fa", "X2fa"},
+	}
+
+	for _, test := range tests {
+		if got := goName(test.name); got != test.want {
+			t.Errorf("goName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
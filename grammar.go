@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var grammarFormats = []string{"textmate"}
+
+// identifiers and attribute names as read by the lexer
+const (
+	grammarIden = `[A-Za-z_][A-Za-z0-9_]*`
+	grammarAttr = `@` + grammarIden + `(\.` + grammarIden + `)*`
+)
+
+// GenerateTextMateGrammar builds the TextMate grammar of the language, the
+// keywords come from the lexer and the types from the generator
+func GenerateTextMateGrammar() (string, error) {
+	keywordNames := []string{}
+	for _, keyword := range keywords {
+		keywordNames = append(keywordNames, regexp.QuoteMeta(string(keyword)))
+	}
+
+	typeNameList := []string{}
+	for _, name := range typeNames() {
+		typeNameList = append(typeNameList, regexp.QuoteMeta(name))
+	}
+
+	grammar := map[string]interface{}{
+		"$schema":   "https://raw.githubusercontent.com/martinring/tmlanguage/master/tmlanguage.json",
+		"name":      "sql-mi",
+		"scopeName": "source.sqmi",
+		"fileTypes": []string{"sqmi"},
+		"patterns": []map[string]string{
+			{"include": "#comments"},
+			{"include": "#table"},
+			{"include": "#keywords"},
+			{"include": "#attributes"},
+			{"include": "#types"},
+			{"include": "#strings"},
+			{"include": "#raw"},
+			{"include": "#numbers"},
+			{"include": "#punctuation"},
+		},
+		"repository": map[string]interface{}{
+			"table": map[string]interface{}{
+				"match": `\b(` + regexp.QuoteMeta(T_TABLE) + `)\s+(` + grammarIden + `)`,
+				"captures": map[string]interface{}{
+					"1": map[string]string{"name": "keyword.control.sqmi"},
+					"2": map[string]string{"name": "entity.name.type.table.sqmi"},
+				},
+			},
+			"comments": map[string]string{
+				"match": `///.*$`,
+				"name":  "comment.line.documentation.sqmi",
+			},
+			"keywords": map[string]string{
+				"match": `\b(` + strings.Join(keywordNames, "|") + `)\b`,
+				"name":  "keyword.control.sqmi",
+			},
+			"attributes": map[string]string{
+				"match": grammarAttr,
+				"name":  "entity.other.attribute-name.sqmi",
+			},
+			"types": map[string]string{
+				"match": `\b(` + strings.Join(typeNameList, "|") + `)\b`,
+				"name":  "support.type.sqmi",
+			},
+			"strings": map[string]string{
+				"begin": `"`,
+				"end":   `"`,
+				"name":  "string.quoted.double.sqmi",
+			},
+			"raw": map[string]string{
+				"begin": "`",
+				"end":   "`",
+				"name":  "string.quoted.other.raw.sqmi",
+			},
+			"numbers": map[string]string{
+				"match": `\b[0-9]+\b`,
+				"name":  "constant.numeric.sqmi",
+			},
+			"punctuation": map[string]string{
+				"match": `[(),]`,
+				"name":  "punctuation.separator.sqmi",
+			},
+		},
+	}
+
+	content, err := json.MarshalIndent(grammar, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+// semantic token types, the index in this legend is what the lsp sends
+var semanticTokenTypes = []string{"keyword", "type", "decorator", "string", "number", "class", "property", "comment"}
+
+func semanticTokenType(name string) int {
+	for i, tokenType := range semanticTokenTypes {
+		if tokenType == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SemanticTokens classifies the tokens of a document and encodes them the
+// way the language server protocol expects: line, start, length, type and
+// modifiers, where line and start are relative to the previous token
+func SemanticTokens(text string) []int {
+	data := []int{}
+	prevLine, prevCol := 1, 1
+	insideTable := false
+
+	// the position of a token in its line decides if an identifier is a
+	// name or a type
+	var prev *Token
+	lineIndex := 0
+
+	t := NewTokenizer(text)
+	for tok := t.NextToken(); tok.TokenType != T_EOF; tok = t.NextToken() {
+		if tok.TokenType == T_EOL {
+			lineIndex = 0
+			prev = nil
+			continue
+		}
+
+		name := ""
+		switch tok.TokenType {
+		case T_TABLE:
+			insideTable = true
+			name = "keyword"
+		case T_END:
+			insideTable = false
+			name = "keyword"
+		case T_SET, T_IMPORT:
+			name = "keyword"
+		case T_ATTR:
+			name = "decorator"
+		case T_STRING, T_RAW:
+			name = "string"
+		case T_NUM:
+			name = "number"
+		case T_DOC:
+			name = "comment"
+		case T_IDEN:
+			switch {
+			case prev != nil && prev.TokenType == T_TABLE:
+				name = "class"
+			case prev != nil && prev.TokenType == T_SET:
+				name = "property"
+			case insideTable && lineIndex == 0:
+				name = "property"
+			case insideTable && lineIndex == 1:
+				name = "type"
+			}
+		}
+
+		prev = tok
+		lineIndex++
+
+		// strings spanning several lines can not be encoded as one token
+		if len(name) == 0 || strings.ContainsAny(tok.Literal, "\r\n") {
+			continue
+		}
+
+		deltaCol := tok.Col - 1
+		if tok.Line == prevLine {
+			deltaCol = tok.Col - prevCol
+		}
+
+		data = append(data, tok.Line-prevLine, deltaCol, tok.End()-tok.Col, semanticTokenType(name), 0)
+		prevLine, prevCol = tok.Line, tok.Col
+	}
+
+	return data
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTextMateGrammar(t *testing.T) {
+	initValues()
+
+	content, err := GenerateTextMateGrammar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var grammar struct {
+		ScopeName  string
+		Repository map[string]struct{ Match string }
+	}
+	err = json.Unmarshal([]byte(content), &grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grammar.ScopeName != "source.sqmi" {
+		t.Errorf("scope %q, want source.sqmi", grammar.ScopeName)
+	}
+
+	pattern := func(name string) *regexp.Regexp {
+		t.Helper()
+		re, err := regexp.Compile(grammar.Repository[name].Match)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return re
+	}
+
+	tests := []struct {
+		rule    string
+		text    string
+		matches []string
+	}{
+		{"table", "table users", []string{"table users"}},
+		{"keywords", "set provider sqlite\nimport \"a.sqmi\"\nend", []string{"set", "import", "end"}},
+		{"keywords", "tables settings", nil},
+		{"types", "id int\nprice decimal(10, 2)\nat timestamptz", []string{"int", "decimal", "timestamptz"}},
+		{"types", "interval", nil},
+		{"attributes", "id int @id @db.postgresql(`SERIAL`)", []string{"@id", "@db.postgresql"}},
+		{"comments", "/// the users", []string{"/// the users"}},
+		{"numbers", "string(255)", []string{"255"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rule+"/"+test.text, func(t *testing.T) {
+			matches := pattern(test.rule).FindAllString(test.text, -1)
+			if !reflect.DeepEqual(matches, test.matches) {
+				t.Errorf("matched %q, want %q", matches, test.matches)
+			}
+		})
+	}
+
+	// every keyword of the lexer and every type is highlighted
+	for _, keyword := range keywords {
+		if !pattern("keywords").MatchString(string(keyword)) {
+			t.Errorf("keyword %q is not highlighted", keyword)
+		}
+	}
+	for _, name := range typeNames() {
+		if !pattern("types").MatchString(name) {
+			t.Errorf("type %q is not highlighted", name)
+		}
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	initValues()
+
+	type token struct {
+		text      string
+		tokenType string
+	}
+
+	tests := []struct {
+		name   string
+		schema string
+		tokens []token
+	}{
+		{
+			"setting",
+			"set provider sqlite\n",
+			[]token{{"set", "keyword"}, {"provider", "property"}},
+		},
+		{
+			"table",
+			"/// the users\ntable users\n\tname string(20) @default(\"x\")\nend\n",
+			[]token{
+				{"/// the users", "comment"},
+				{"table", "keyword"},
+				{"users", "class"},
+				{"name", "property"},
+				{"string", "type"},
+				{"20", "number"},
+				{"@default", "decorator"},
+				{"\"x\"", "string"},
+				{"end", "keyword"},
+			},
+		},
+		{
+			"raw value after a colmun",
+			"table t\n\tat datetime @default(`CURRENT_TIMESTAMP`)\nend\n",
+			[]token{{"table", "keyword"}, {"t", "class"}, {"at", "property"}, {"datetime", "type"}, {"@default", "decorator"}, {"`CURRENT_TIMESTAMP`", "string"}, {"end", "keyword"}},
+		},
+		{
+			"import",
+			"import \"users.sqmi\"\n",
+			[]token{{"import", "keyword"}, {"\"users.sqmi\"", "string"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := strings.Split(test.schema, "\n")
+			data := SemanticTokens(test.schema)
+			if len(data)%5 != 0 {
+				t.Fatalf("data %v is not made of groups of 5", data)
+			}
+
+			// positions are relative to the previous token
+			tokens := []token{}
+			line, col := 0, 0
+			for i := 0; i < len(data); i += 5 {
+				if data[i] > 0 {
+					col = 0
+				}
+				line += data[i]
+				col += data[i+1]
+				text := lines[line][col : col+data[i+2]]
+				tokens = append(tokens, token{text, semanticTokenTypes[data[i+3]]})
+			}
+
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Errorf("tokens %q, want %q", tokens, test.tokens)
+			}
+		})
+	}
+}
+
+func TestParseGrammarArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		error string
+	}{
+		{[]string{}, ""},
+		{[]string{"--format", "textmate", "-o", "sqmi.tmLanguage.json"}, ""},
+		{[]string{"--format", "sublime"}, "Unknown format 'sublime', expected textmate"},
+		{[]string{"schema.sqmi"}, "grammar takes no arguments"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			_, err := ParseGrammarArgs(test.args)
+			if len(test.error) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// the type sqlite gives each logical type, read back as the logical type.
+// Other declared types are kept as raw types
+var sqliteLogicalTypes = map[string]string{
+	"INTEGER": "int",
+	"TEXT":    "string",
+	"REAL":    "float",
+	"BLOB":    "bytes",
+	"NUMERIC": "decimal",
+}
+
+type sqliteColmun struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	Default    sql.NullString
+	PrimaryKey int
+}
+
+// IntrospectSQLite reads the tables of a sqlite database into an ast, the
+// migration history of sql-mi is left out. What can not be expressed, like
+// keys on several colmuns, is returned as warnings
+func IntrospectSQLite(db *sql.DB, url string) (*AST, []string, error) {
+	initValues()
+
+	ast := &AST{
+		map[string]string{"provider": "sqlite", "url": url},
+		[]*TabelAST{},
+		[]string{},
+		[]*SettingAST{{"provider", "sqlite", Position{}}, {"url", url, Position{}}},
+		[]*ImportAST{},
+	}
+	warnings := []string{}
+
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%' AND name != '%s' ORDER BY rowid",
+		migrationsTable,
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error: Could not read the tables: %v", err)
+	}
+	names, statements := []string{}, map[string]string{}
+	for rows.Next() {
+		name, statement := "", ""
+		err = rows.Scan(&name, &statement)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("Error: Could not read the tables: %v", err)
+		}
+		names = append(names, name)
+		statements[name] = statement
+	}
+	rows.Close()
+
+	for _, name := range names {
+		table, tableWarnings, err := introspectTable(db, name, statements[name])
+		if err != nil {
+			return nil, nil, err
+		}
+		ast.Tables = append(ast.Tables, table)
+		warnings = append(warnings, tableWarnings...)
+	}
+
+	// references need every table to find implicit target colmuns
+	for _, table := range ast.Tables {
+		refWarnings, err := introspectReferences(db, ast, table)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, refWarnings...)
+	}
+
+	sorted, sortWarnings := sortTablesByReferences(ast)
+	ast.Tables = sorted
+	return ast, append(warnings, sortWarnings...), nil
+}
+
+func introspectTable(db *sql.DB, name string, statement string) (*TabelAST, []string, error) {
+	table := &TabelAST{name, []*ColmunAST{}, []*ReferenceAST{}, &AttributesAST{}, Position{}, ""}
+	warnings := []string{}
+
+	if !isValidTableName(name) {
+		return nil, nil, fmt.Errorf("Error: Table '%s' has a name sql-mi does not support", name)
+	}
+
+	colmuns, err := sqliteTableInfo(db, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	primaryKeys := 0
+	for _, info := range colmuns {
+		if info.PrimaryKey > 0 {
+			primaryKeys++
+		}
+	}
+	if primaryKeys > 1 {
+		warnings = append(warnings, fmt.Sprintf("The primary key of table '%s' has several colmuns, skipped", name))
+	}
+
+	for _, info := range colmuns {
+		colmun := &ColmunAST{info.Name, "", []string{}, &AttributesAST{}, Position{}, ""}
+		attrs := *colmun.Attributes
+		setAttr := func(name string, args ...*AttributeArgAST) {
+			if args == nil {
+				args = []*AttributeArgAST{}
+			}
+			attrs[name] = &AttributeAST{name, args, Position{}}
+		}
+
+		declared := strings.Join(strings.Fields(info.Type), " ")
+		if logical, exists := sqliteLogicalTypes[strings.ToUpper(declared)]; exists {
+			colmun.Data_type = logical
+		} else {
+			colmun.Data_type = "raw"
+			setAttr("raw", &AttributeArgAST{declared, "string", Position{}})
+		}
+
+		isKey := info.PrimaryKey > 0 && primaryKeys == 1
+		if isKey {
+			setAttr("id")
+			// only an INTEGER PRIMARY KEY can be AUTOINCREMENT
+			if strings.Contains(strings.ToUpper(statement), "AUTOINCREMENT") {
+				setAttr("auto_increment")
+			}
+		}
+
+		// the primary key is not null even when not declared so
+		if !info.NotNull && !isKey {
+			setAttr("nullable")
+		}
+
+		if info.Default.Valid {
+			setAttr("default", sqliteDefault(info.Default.String))
+		}
+
+		table.Colmuns = append(table.Colmuns, colmun)
+	}
+
+	unique, uniqueWarnings, err := sqliteUniqueColmuns(db, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, colmun := range table.Colmuns {
+		if unique[colmun.Name] {
+			(*colmun.Attributes)["unique"] = &AttributeAST{"unique", []*AttributeArgAST{}, Position{}}
+		}
+	}
+
+	return table, append(warnings, uniqueWarnings...), nil
+}
+
+func sqliteTableInfo(db *sql.DB, table string) ([]*sqliteColmun, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", sqliteQuote(table)))
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not read the colmuns of '%s': %v", table, err)
+	}
+	defer rows.Close()
+
+	colmuns := []*sqliteColmun{}
+	for rows.Next() {
+		cid := 0
+		info := &sqliteColmun{}
+		err = rows.Scan(&cid, &info.Name, &info.Type, &info.NotNull, &info.Default, &info.PrimaryKey)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Could not read the colmuns of '%s': %v", table, err)
+		}
+		colmuns = append(colmuns, info)
+	}
+	return colmuns, rows.Err()
+}
+
+// sqliteUniqueColmuns finds the colmuns declared UNIQUE on their own
+func sqliteUniqueColmuns(db *sql.DB, table string) (map[string]bool, []string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA index_list(%s)", sqliteQuote(table)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error: Could not read the indexes of '%s': %v", table, err)
+	}
+
+	indexes := []string{}
+	for rows.Next() {
+		seq, unique, partial := 0, false, false
+		name, origin := "", ""
+		err = rows.Scan(&seq, &name, &unique, &origin, &partial)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("Error: Could not read the indexes of '%s': %v", table, err)
+		}
+		if unique && origin == "u" {
+			indexes = append(indexes, name)
+		}
+	}
+	rows.Close()
+
+	colmuns := map[string]bool{}
+	warnings := []string{}
+	for _, index := range indexes {
+		names, err := sqliteIndexColmuns(db, index)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(names) != 1 {
+			warnings = append(warnings, fmt.Sprintf("Unique constraint of table '%s' on several colmuns, skipped", table))
+			continue
+		}
+		colmuns[names[0]] = true
+	}
+	return colmuns, warnings, nil
+}
+
+func sqliteIndexColmuns(db *sql.DB, index string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA index_info(%s)", sqliteQuote(index)))
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not read index '%s': %v", index, err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		seqno, cid, name := 0, 0, ""
+		err = rows.Scan(&seqno, &cid, &name)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Could not read index '%s': %v", index, err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func introspectReferences(db *sql.DB, ast *AST, table *TabelAST) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA foreign_key_list(%s)", sqliteQuote(table.Name)))
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not read the foreign keys of '%s': %v", table.Name, err)
+	}
+	defer rows.Close()
+
+	refs := map[int]*ReferenceAST{}
+	order := []int{}
+	composite := map[int]bool{}
+	for rows.Next() {
+		id, seq := 0, 0
+		target, from, onUpdate, onDelete, match := "", "", "", "", ""
+		to := sql.NullString{}
+		err = rows.Scan(&id, &seq, &target, &from, &to, &onUpdate, &onDelete, &match)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Could not read the foreign keys of '%s': %v", table.Name, err)
+		}
+
+		if _, exists := refs[id]; exists {
+			composite[id] = true
+			continue
+		}
+
+		ref := &ReferenceAST{target, to.String, from, sqliteAction(onDelete), sqliteAction(onUpdate)}
+		refs[id] = ref
+		order = append(order, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	warnings := []string{}
+	for _, id := range order {
+		ref := refs[id]
+		if composite[id] {
+			warnings = append(warnings, fmt.Sprintf("Foreign key of table '%s' on several colmuns, skipped", table.Name))
+			continue
+		}
+
+		target := findTable(ast, ref.TargetTable)
+		if target == nil {
+			warnings = append(warnings, fmt.Sprintf("Foreign key %s.%s references missing table '%s', skipped", table.Name, ref.SourceCol, ref.TargetTable))
+			continue
+		}
+
+		// REFERENCES users without a colmun means its primary key
+		if len(ref.TargetCol) == 0 {
+			for _, colmun := range target.Colmuns {
+				if _, exists := (*colmun.Attributes)["id"]; exists {
+					ref.TargetCol = colmun.Name
+				}
+			}
+		}
+		if findColmun(target, ref.TargetCol) == nil {
+			warnings = append(warnings, fmt.Sprintf("Foreign key %s.%s references missing colmun '%s.%s', skipped", table.Name, ref.SourceCol, ref.TargetTable, ref.TargetCol))
+			continue
+		}
+
+		table.References = append(table.References, ref)
+	}
+
+	return warnings, nil
+}
+
+// sqliteDefault reads a default as sqlite stores it, quoted strings become
+// string defaults and everything else stays an expression
+func sqliteDefault(value string) *AttributeArgAST {
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return &AttributeArgAST{strings.ReplaceAll(value[1:len(value)-1], "''", "'"), "string", Position{}}
+	}
+	return &AttributeArgAST{value, "raw", Position{}}
+}
+
+// NO ACTION is what sqlite reports when no action was declared
+func sqliteAction(action string) string {
+	if action == "NO ACTION" {
+		return ""
+	}
+	return action
+}
+
+func sqliteQuote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
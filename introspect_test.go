@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// createDatabase makes a sqlite database from sql statements
+func createDatabase(t *testing.T, statements ...string) (*sql.DB, string) {
+	t.Helper()
+
+	url := filepath.Join(t.TempDir(), "app.db")
+	db, err := openDatabase(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, statement := range statements {
+		_, err = db.Exec(statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, url
+}
+
+func TestIntrospectSQLite(t *testing.T) {
+	db, url := createDatabase(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL UNIQUE, name TEXT DEFAULT 'it''s', score REAL DEFAULT 0, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, manager INTEGER REFERENCES users (id))`,
+		`CREATE TABLE posts (id INTEGER PRIMARY KEY, author INTEGER NOT NULL REFERENCES users ON DELETE CASCADE, body BLOB, price NUMERIC, ghost INTEGER REFERENCES missing (id))`,
+		`CREATE TABLE pairs (a INTEGER, b INTEGER, c INTEGER, d INTEGER, PRIMARY KEY (a, b), UNIQUE (c, d), FOREIGN KEY (c, d) REFERENCES pairs (a, b))`,
+		"CREATE TABLE "+migrationsTable+" (name TEXT)",
+	)
+
+	ast, warnings, err := IntrospectSQLite(db, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `set provider sqlite
+set url "` + url + `"
+
+table users
+	id         int        @id @auto_increment
+	email      string     @unique
+	name       string     @default("it's") @nullable
+	score      float      @default(` + "`0`" + `) @nullable
+	created_at ` + "`DATETIME`" + ` @default(` + "`CURRENT_TIMESTAMP`" + `) @nullable
+	manager    int        @nullable @reference("users", "id")
+end
+
+table posts
+	id     int     @id
+	author int     @reference("users", "id") @onDelete("CASCADE")
+	body   bytes   @nullable
+	price  decimal @nullable
+	ghost  int     @nullable
+end
+
+table pairs
+	a int @nullable
+	b int @nullable
+	c int @nullable
+	d int @nullable
+end
+`
+	if schema := Format(ast); schema != want {
+		t.Errorf("got:\n%s\nwant:\n%s", schema, want)
+	}
+
+	wantWarnings := []string{
+		"The primary key of table 'pairs' has several colmuns, skipped",
+		"Unique constraint of table 'pairs' on several colmuns, skipped",
+		"Foreign key posts.ghost references missing table 'missing', skipped",
+		"Foreign key of table 'pairs' on several colmuns, skipped",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings %q, want %q", warnings, wantWarnings)
+	}
+}
+
+// what introspect writes is a schema that generates the same tables again
+func TestIntrospectRoundTrip(t *testing.T) {
+	schema := parseSchema(t, "sqlite", `
+table users
+	id int @id @auto_increment
+	email string @unique
+	active bool @default("true")
+	created datetime @default(`+"`CURRENT_TIMESTAMP`"+`)
+	bio text @nullable
+end
+table posts
+	id int @id
+	author int @reference("users", "id") @onDelete("CASCADE") @onUpdate("SET NULL")
+end
+`)
+	sql, err := GenerateSQL(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, url := createDatabase(t, splitStatements(sql)...)
+	ast, warnings, err := IntrospectSQLite(db, url)
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("warnings %q: %v", warnings, err)
+	}
+
+	issues, err := Drift(schema, ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) > 0 {
+		t.Errorf("introspected schema differs: %q", issues)
+	}
+}
+
+func TestSqliteDefault(t *testing.T) {
+	tests := []struct {
+		value string
+		want  AttributeArgAST
+	}{
+		{"'abc'", AttributeArgAST{"abc", "string", Position{}}},
+		{"'it''s'", AttributeArgAST{"it's", "string", Position{}}},
+		{"''", AttributeArgAST{"", "string", Position{}}},
+		{"0", AttributeArgAST{"0", "raw", Position{}}},
+		{"CURRENT_TIMESTAMP", AttributeArgAST{"CURRENT_TIMESTAMP", "raw", Position{}}},
+		{"'", AttributeArgAST{"'", "raw", Position{}}},
+	}
+
+	for _, test := range tests {
+		if got := sqliteDefault(test.value); *got != test.want {
+			t.Errorf("sqliteDefault(%q) = %+v, want %+v", test.value, *got, test.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// the json schema of every logical type, parameters and @nullable are
+// applied on top of it. Json and raw sql types accept any value
+var jsonSchemaTypes = map[string]map[string]interface{}{
+	"int":         {"type": "integer"},
+	"bigint":      {"type": "integer"},
+	"smallint":    {"type": "integer", "minimum": -32768, "maximum": 32767},
+	"float":       {"type": "number"},
+	"decimal":     {"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?$`},
+	"string":      {"type": "string"},
+	"char":        {"type": "string"},
+	"text":        {"type": "string"},
+	"uuid":        {"type": "string", "format": "uuid"},
+	"bool":        {"type": "boolean"},
+	"boolean":     {"type": "boolean"},
+	"datetime":    {"type": "string", "format": "date-time"},
+	"timestamptz": {"type": "string", "format": "date-time"},
+	"date":        {"type": "string", "format": "date"},
+	"time":        {"type": "string", "format": "time"},
+	"blob":        {"type": "string", "contentEncoding": "base64"},
+	"bytes":       {"type": "string", "contentEncoding": "base64"},
+	"json":        {},
+	"raw":         {},
+}
+
+// jsonObject keeps the order its keys were set in, so properties come in the
+// order of the colmuns
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{[]string{}, map[string]interface{}{}}
+}
+
+func (o *jsonObject) Set(key string, value interface{}) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString("{")
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// GenerateJSONSchema writes one json schema document with a definition per
+// table under $defs. Colmuns that are not @nullable and have no default or
+// @auto_increment are required
+func GenerateJSONSchema(ast *AST, cfg *Config) (string, error) {
+	defs := newJSONObject()
+
+	for _, table := range ast.Tables {
+		properties := newJSONObject()
+		required := []string{}
+
+		for _, colmun := range table.Colmuns {
+			schema, err := jsonSchemaColmun(colmun)
+			if err != nil {
+				return "", err
+			}
+			properties.Set(colmun.Name, schema)
+
+			_, hasDefault := (*colmun.Attributes)["default"]
+			_, autoIncrement := (*colmun.Attributes)["auto_increment"]
+			if !isNullable(colmun) && !hasDefault && !autoIncrement {
+				required = append(required, colmun.Name)
+			}
+		}
+
+		def := newJSONObject()
+		def.Set("type", "object")
+		if len(table.Doc) > 0 {
+			def.Set("description", table.Doc)
+		}
+		def.Set("properties", properties)
+		def.Set("required", required)
+		def.Set("additionalProperties", false)
+		defs.Set(table.Name, def)
+	}
+
+	document := newJSONObject()
+	document.Set("$schema", "https://json-schema.org/draft/2020-12/schema")
+	document.Set("$defs", defs)
+
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+func jsonSchemaColmun(colmun *ColmunAST) (*jsonObject, error) {
+	base, exists := jsonSchemaTypes[colmun.Data_type]
+	if !exists {
+		return nil, fmt.Errorf("Error: Type '%s' of colmun '%s' has no json schema type", colmun.Data_type, colmun.Name)
+	}
+
+	schema := newJSONObject()
+	for _, key := range []string{"type", "format", "pattern", "contentEncoding", "minimum", "maximum"} {
+		if value, exists := base[key]; exists {
+			schema.Set(key, value)
+		}
+	}
+
+	// string(n) and char(n) hold at most n characters
+	if (colmun.Data_type == "string" || colmun.Data_type == "char") && len(colmun.Type_params) == 1 {
+		length, err := strconv.Atoi(colmun.Type_params[0])
+		if err == nil {
+			schema.Set("maxLength", length)
+		}
+	}
+
+	if isNullable(colmun) {
+		if jsonType, exists := base["type"]; exists {
+			schema.Set("type", []interface{}{jsonType, "null"})
+		}
+	}
+
+	if len(colmun.Doc) > 0 {
+		schema.Set("description", colmun.Doc)
+	}
+
+	// sql expressions like CURRENT_TIMESTAMP have no json value
+	if attr, exists := (*colmun.Attributes)["default"]; exists && len(attr.Values) == 1 && attr.Values[0].Type != "raw" {
+		schema.Set("default", jsonDefault(colmun.Data_type, attr.Values[0].Value))
+	}
+
+	return schema, nil
+}
+
+// jsonDefault turns a default into the json value of the colmun type, the
+// checks already made sure it is valid
+func jsonDefault(dataType string, value string) interface{} {
+	switch jsonSchemaTypes[dataType]["type"] {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		return value == "true" || value == "1"
+	}
+
+	if dataType == "json" && json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+
+	return value
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGenerateJSONSchema(t *testing.T) {
+	schema := parseSchema(t, "postgresql", `
+/// People who can sign in.
+table users
+	id int @id @auto_increment
+	/// Where we write to
+	email string(255) @unique
+	bio text @nullable
+	active bool @default("1")
+	created_at datetime @default(`+"`CURRENT_TIMESTAMP`"+`)
+end
+table events
+	key uuid @id
+	level smallint @default("3")
+	price decimal @nullable
+	payload json @default("[1, 2, {}]")
+	body bytes
+	day date
+	span `+"`INTERVAL`"+` @nullable
+end
+`)
+
+	content, err := GenerateJSONSchema(schema, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$defs": {
+    "users": {
+      "type": "object",
+      "description": "People who can sign in.",
+      "properties": {
+        "id": {
+          "type": "integer"
+        },
+        "email": {
+          "type": "string",
+          "maxLength": 255,
+          "description": "Where we write to"
+        },
+        "bio": {
+          "type": [
+            "string",
+            "null"
+          ]
+        },
+        "active": {
+          "type": "boolean",
+          "default": true
+        },
+        "created_at": {
+          "type": "string",
+          "format": "date-time"
+        }
+      },
+      "required": [
+        "email"
+      ],
+      "additionalProperties": false
+    },
+    "events": {
+      "type": "object",
+      "properties": {
+        "key": {
+          "type": "string",
+          "format": "uuid"
+        },
+        "level": {
+          "type": "integer",
+          "minimum": -32768,
+          "maximum": 32767,
+          "default": 3
+        },
+        "price": {
+          "type": [
+            "string",
+            "null"
+          ],
+          "pattern": "^-?[0-9]+(\\.[0-9]+)?$"
+        },
+        "payload": {
+          "default": [
+            1,
+            2,
+            {}
+          ]
+        },
+        "body": {
+          "type": "string",
+          "contentEncoding": "base64"
+        },
+        "day": {
+          "type": "string",
+          "format": "date"
+        },
+        "span": {}
+      },
+      "required": [
+        "key",
+        "body",
+        "day"
+      ],
+      "additionalProperties": false
+    }
+  }
+}
+`
+	if content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestJSONDefault(t *testing.T) {
+	tests := []struct {
+		dataType string
+		value    string
+		want     string
+	}{
+		{"int", "42", "42"},
+		{"bigint", "-9000000000", "-9000000000"},
+		{"float", "4.5", "4.5"},
+		{"bool", "true", "true"},
+		{"bool", "0", "false"},
+		{"string", "42", `"42"`},
+		{"decimal", "19.99", `"19.99"`},
+		{"json", `{"a": 1}`, `{"a":1}`},
+		{"datetime", "2024-01-02", `"2024-01-02"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dataType+"/"+test.value, func(t *testing.T) {
+			value, err := json.Marshal(jsonDefault(test.dataType, test.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != test.want {
+				t.Errorf("default %s, want %s", value, test.want)
+			}
+		})
+	}
+}
+
+// every logical type has a json schema
+func TestJSONSchemaTypes(t *testing.T) {
+	initValues()
+
+	for _, name := range typeNames() {
+		if _, exists := jsonSchemaTypes[name]; !exists {
+			t.Errorf("type %q has no json schema", name)
+		}
+	}
+}
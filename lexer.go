@@ -1,10 +1,13 @@
 package main
 
+import "strings"
+
 type TokenType string
 
 type Token struct {
 	TokenType TokenType
 	Literal   string
+	File      string
 	Line      int
 	Col       int
 }
@@ -17,6 +20,7 @@ const (
 	T_TABLE       = "table"
 	T_END         = "end"
 	T_SET         = "set"
+	T_IMPORT      = "import"
 	T_LEFT_PAREN  = "LeftParan"
 	T_RIGHT_PAREN = "RightParan"
 	T_COMMA       = "Comma"
@@ -26,6 +30,7 @@ const (
 	T_STRING  = "String"
 	T_RAW     = "Raw"
 	T_NUM     = "Number"
+	T_DOC     = "Doc"
 	T_ILLEGAL = "Illegal"
 	T_ERROR   = "Error"
 )
@@ -39,25 +44,40 @@ func createToken(tokenType TokenType, value string, line int, col int) *Token {
 	}
 }
 
+// End gives the colmun right after the token
+func (tok *Token) End() int {
+	switch tok.TokenType {
+	case T_EOF, T_EOL:
+		return tok.Col
+	case T_STRING, T_RAW:
+		return tok.Col + len(tok.Literal) + 2
+	case T_DOC:
+		return tok.Col + len(tok.Literal) + 3
+	case T_ATTR:
+		return tok.Col + len(tok.Literal) + 1
+	}
+	return tok.Col + len(tok.Literal)
+}
+
+// keywords of the language, the grammar and semantic tokens are generated
+// from this list so editors stay in sync with the lexer
+var keywords = []TokenType{T_TABLE, T_END, T_SET, T_IMPORT}
+
 func getToken(literal string, line int, col int) *Token {
 	var tokenType TokenType = T_IDEN
 
-	switch literal {
-	case T_TABLE:
-		tokenType = T_TABLE
-		break
-	case T_END:
-		tokenType = T_END
-	case T_SET:
-		tokenType = T_SET
-	default:
-		break
+	for _, keyword := range keywords {
+		if literal == string(keyword) {
+			tokenType = keyword
+			break
+		}
 	}
 
 	return createToken(tokenType, literal, line, col)
 }
 
 type Tokenizer struct {
+	file  string
 	input string
 	pos   int
 	line  int
@@ -66,7 +86,14 @@ type Tokenizer struct {
 }
 
 func NewTokenizer(input string) *Tokenizer {
+	return NewFileTokenizer("", input)
+}
+
+// NewFileTokenizer creates a tokenizer whose tokens remember the file they
+// come from, used for error positions in multi file schemas
+func NewFileTokenizer(file string, input string) *Tokenizer {
 	return &Tokenizer{
+		file,
 		input,
 		0,
 		1,
@@ -76,6 +103,12 @@ func NewTokenizer(input string) *Tokenizer {
 }
 
 func (t *Tokenizer) NextToken() *Token {
+	tok := t.nextToken()
+	tok.File = t.file
+	return tok
+}
+
+func (t *Tokenizer) nextToken() *Token {
 
 	ch := t.readChar()
 
@@ -91,7 +124,7 @@ func (t *Tokenizer) NextToken() *Token {
 
 	//skip whitespace
 	if isWhitespace(ch) {
-		return t.NextToken()
+		return t.nextToken()
 	}
 
 	switch ch {
@@ -106,7 +139,7 @@ func (t *Tokenizer) NextToken() *Token {
 		if isWhitespace(ch) {
 			break
 		} else {
-			literal := t.readIden()
+			literal := t.readAttrName()
 			return createToken(T_ATTR, literal, t.line, startCol)
 		}
 	case '(':
@@ -121,6 +154,14 @@ func (t *Tokenizer) NextToken() *Token {
 	case '`':
 		literal := t.readString('`')
 		return createToken(T_RAW, literal, t.line, startCol)
+	case '/':
+		// doc comments start with /// and go until the end of the line
+		if t.peekString("//") {
+			t.nextChar()
+			t.nextChar()
+			literal := t.readLine()
+			return createToken(T_DOC, literal, t.line, startCol)
+		}
 	}
 
 	if isLetter(ch) {
@@ -137,6 +178,43 @@ func (t *Tokenizer) NextToken() *Token {
 	return createToken(T_ILLEGAL, string(ch), t.line, startCol)
 }
 
+func (t *Tokenizer) File() string {
+	return t.file
+}
+
+// EndOf gives the colmun right after the token that starts at line and col,
+// used to give diagnostics a range
+func (t *Tokenizer) EndOf(line int, col int) int {
+	pos := 0
+	for l := 1; l < line && pos < len(t.input); pos++ {
+		if t.input[pos] == '\n' {
+			l++
+		}
+	}
+	pos += col - 1
+
+	if pos < 0 || pos >= len(t.input) || isEOL(t.input[pos]) {
+		return col
+	}
+
+	end := pos + 1
+	switch ch := t.input[pos]; {
+	case ch == '"' || ch == '`':
+		for end < len(t.input) && t.input[end] != ch && !isEOL(t.input[end]) {
+			end++
+		}
+		if end < len(t.input) && t.input[end] == ch {
+			end++
+		}
+	case ch == '@' || isLetter(ch) || isNumber(ch):
+		for end < len(t.input) && (isLetter(t.input[end]) || isNumber(t.input[end]) || t.input[end] == '.') {
+			end++
+		}
+	}
+
+	return col + end - pos
+}
+
 func (t *Tokenizer) PeekToken() *Token {
 	line := t.line
 	col := t.col
@@ -193,6 +271,36 @@ func (t *Tokenizer) readIden() string {
 	return string(iden)
 }
 
+// attribute names can be namespaced with dots like @db.postgresql
+func (t *Tokenizer) readAttrName() string {
+	name := t.readIden()
+
+	for t.readChar() == '.' {
+		t.nextChar()
+		name += "."
+
+		if !isLetter(t.readChar()) {
+			break
+		}
+		t.nextChar()
+		name += t.readIden()
+	}
+
+	return name
+}
+
+func (t *Tokenizer) peekString(s string) bool {
+	return strings.HasPrefix(t.input[t.pos:], s)
+}
+
+func (t *Tokenizer) readLine() string {
+	start := t.pos
+	for !t.isEOF() && !isEOL(t.input[t.pos]) {
+		t.nextChar()
+	}
+	return strings.TrimRight(t.input[start:t.pos], " \t")
+}
+
 func (t *Tokenizer) readNum() string {
 	num := []rune{rune(t.ch)}
 
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var namingConventions = map[string]*regexp.Regexp{
+	"snake_case": regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+	"camelCase":  regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	"PascalCase": regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+}
+
+// every rule is off unless the project configuration turns it on, or it is
+// listed in defaultLintRules
+var lintRules = map[string]func(ast *AST, project *ProjectConfig) []*Diagnostic{
+	"naming":              lintNaming,
+	"missing-primary-key": lintMissingPrimaryKey,
+	"renamed-from":        lintRenamedFrom,
+}
+
+// rules on unless the project configuration turns them off, an applied
+// @renamedFrom is easy to forget once its migration is created
+var defaultLintRules = map[string]string{
+	"renamed-from": "warning",
+}
+
+// Lint runs the default rules and the rules enabled in the project
+// configuration, issues are sorted by position
+func Lint(ast *AST, project *ProjectConfig) []*Diagnostic {
+	issues := []*Diagnostic{}
+
+	rules := map[string]string{}
+	for rule, severity := range defaultLintRules {
+		rules[rule] = severity
+	}
+	for rule, severity := range project.Lint {
+		rules[rule] = severity
+	}
+
+	for rule, severity := range rules {
+		if severity == "off" {
+			continue
+		}
+
+		for _, issue := range lintRules[rule](ast, project) {
+			issue.Kind = "Lint"
+			issue.Code = rule
+			issue.Severity = severity
+			issues = append(issues, issue)
+		}
+	}
+
+	sortDiagnostics(issues)
+	return issues
+}
+
+func lintNaming(ast *AST, project *ProjectConfig) []*Diagnostic {
+	issues := []*Diagnostic{}
+
+	tablePattern := namingConventions[project.Naming.Tables]
+	colPattern := namingConventions[project.Naming.Colmuns]
+
+	for _, table := range ast.Tables {
+		if tablePattern != nil && !tablePattern.MatchString(table.Name) {
+			issues = append(issues, &Diagnostic{
+				Pos:     table.Pos,
+				End:     nameEnd(table.Pos, table.Name),
+				Message: fmt.Sprintf("Table name '%s' is not %s", table.Name, project.Naming.Tables),
+			})
+		}
+
+		if colPattern == nil {
+			continue
+		}
+
+		for _, colmun := range table.Colmuns {
+			if !colPattern.MatchString(colmun.Name) {
+				issues = append(issues, &Diagnostic{
+					Pos:     colmun.Pos,
+					End:     nameEnd(colmun.Pos, colmun.Name),
+					Message: fmt.Sprintf("Colmun name '%s' is not %s", colmun.Name, project.Naming.Colmuns),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+func lintMissingPrimaryKey(ast *AST, project *ProjectConfig) []*Diagnostic {
+	issues := []*Diagnostic{}
+
+	for _, table := range ast.Tables {
+		hasId := false
+		for _, colmun := range table.Colmuns {
+			if _, exists := (*colmun.Attributes)["id"]; exists {
+				hasId = true
+			}
+		}
+
+		if !hasId {
+			issues = append(issues, &Diagnostic{
+				Pos:     table.Pos,
+				End:     nameEnd(table.Pos, table.Name),
+				Message: fmt.Sprintf("Table '%s' has no @id colmun", table.Name),
+			})
+		}
+	}
+
+	return issues
+}
+
+// lintRenamedFrom finds @renamedFrom hints the last migration already applied,
+// they are not needed anymore
+func lintRenamedFrom(ast *AST, project *ProjectConfig) []*Diagnostic {
+	issues := []*Diagnostic{}
+
+	snapshot, err := latestSnapshot(migrationsDir("", project), ast.Configuration["provider"])
+	if err != nil {
+		return issues
+	}
+
+	applied := func(attributes *AttributesAST) {
+		previous := renamedFrom(attributes)
+		attr := (*attributes)["renamedFrom"]
+		issues = append(issues, &Diagnostic{
+			Pos:     attr.Pos,
+			End:     nameEnd(attr.Pos, "@renamedFrom"),
+			Message: fmt.Sprintf("@renamedFrom(\"%s\") was applied by the last migration, remove it", previous),
+		})
+	}
+
+	for _, table := range ast.Tables {
+		oldTable := findTable(snapshot, table.Name)
+		if oldTable == nil {
+			continue
+		}
+
+		if previous := renamedFrom(table.Attributes); len(previous) > 0 && findTable(snapshot, previous) == nil {
+			applied(table.Attributes)
+		}
+
+		for _, colmun := range table.Colmuns {
+			previous := renamedFrom(colmun.Attributes)
+			if len(previous) > 0 && findColmun(oldTable, colmun.Name) != nil && findColmun(oldTable, previous) == nil {
+				applied(colmun.Attributes)
+			}
+		}
+	}
+
+	return issues
+}
+
+func nameEnd(pos Position, name string) Position {
+	return Position{pos.File, pos.Line, pos.Col + len(name)}
+}
@@ -0,0 +1,687 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// documentation shown on hover and completion
+var attributeDocs = map[string]string{
+	"id":             "Marks the colmun as the primary key.",
+	"default":        "Sets the default value of the colmun. Use a raw value like @default(`CURRENT_TIMESTAMP`) for sql expressions.",
+	"auto_increment": "Lets the database generate increasing values for the colmun.",
+	"nullable":       "Allows null values, colmuns are NOT NULL otherwise.",
+	"unique":         "No two rows can have the same value in this colmun.",
+	"reference":      "Declares a foreign key: @reference(\"table\", \"colmun\").",
+	"onDelete":       "What happens to the row when the referenced row is deleted, e.g. @onDelete(\"CASCADE\").",
+	"onUpdate":       "What happens to the row when the referenced key changes, e.g. @onUpdate(\"CASCADE\").",
+	"db":             "Overrides the colmun type for one provider, e.g. @db.postgresql(`JSONB`).",
+	"renamedFrom":    "Tells migrations the colmun or table was renamed from this name, so its data is kept: @renamedFrom(\"old_name\").",
+}
+
+var keywordDocs = map[string]string{
+	T_TABLE:  "Starts a table: `table <name>`, closed by `end`.",
+	T_END:    "Closes a table.",
+	T_SET:    "Sets a schema option: `set provider <name>` or `set url \"<url>\"`.",
+	T_IMPORT: "Imports another schema file: `import \"file.sqmi\"`.",
+}
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspDocumentParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	Position       lspPosition     `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+}
+
+// completion item kinds from the protocol
+const (
+	lspKindField    = 5
+	lspKindProperty = 10
+	lspKindValue    = 12
+	lspKindKeyword  = 14
+	lspKindStruct   = 22
+	lspKindType     = 25
+)
+
+type lspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]string
+	asts      map[string]*AST
+	project   *ProjectConfig
+	shutdown  bool
+}
+
+// RunLSP serves the language server protocol until the client sends exit,
+// the error is nil when the client asked for a shutdown first
+func RunLSP(in io.Reader, out io.Writer, project *ProjectConfig) error {
+	initValues()
+	initParseAttrFuncs()
+
+	server := &lspServer{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]string{},
+		asts:      map[string]*AST{},
+		project:   project,
+	}
+
+	for {
+		msg, err := server.read()
+		if err == io.EOF {
+			return fmt.Errorf("Error: Client closed the connection")
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !server.shutdown {
+				return fmt.Errorf("Error: Exit without shutdown")
+			}
+			return nil
+		}
+
+		err = server.handle(msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+
+	// headers end with an empty line
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("Error: Bad Content-Length '%s'", value)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("Error: Missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &lspMessage{}
+	err = json.Unmarshal(body, msg)
+	if err != nil {
+		return nil, fmt.Errorf("Error: Bad message: %v", err)
+	}
+
+	return msg, nil
+}
+
+func (s *lspServer) write(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *lspServer) reply(id *json.RawMessage, result interface{}) error {
+	return s.write(lspResponse{"2.0", id, result})
+}
+
+func (s *lspServer) handle(msg *lspMessage) error {
+	params := &lspDocumentParams{}
+	if len(msg.Params) > 0 {
+		// params that are not about a document are not needed
+		json.Unmarshal(msg.Params, params)
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": 1,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"@", "\"", " "},
+				},
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"documentFormattingProvider": true,
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{
+						"tokenTypes":     semanticTokenTypes,
+						"tokenModifiers": []string{},
+					},
+					"full": true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "sql-mi", "version": version},
+		})
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(msg.ID, nil)
+	case "textDocument/didOpen":
+		s.documents[uri] = params.TextDocument.Text
+		return s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		// full sync, the last change holds the whole document
+		if len(params.ContentChanges) > 0 {
+			s.documents[uri] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		}
+		return s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.documents, uri)
+		delete(s.asts, uri)
+		return s.write(lspNotification{"2.0", "textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         uri,
+			"diagnostics": []lspDiagnostic{},
+		}})
+	case "textDocument/completion":
+		return s.reply(msg.ID, s.completion(uri, params.Position))
+	case "textDocument/hover":
+		return s.reply(msg.ID, s.hover(uri, params.Position))
+	case "textDocument/definition":
+		return s.reply(msg.ID, s.definition(uri, params.Position))
+	case "textDocument/formatting":
+		return s.reply(msg.ID, s.formatting(uri))
+	case "textDocument/semanticTokens/full":
+		return s.reply(msg.ID, map[string][]int{"data": SemanticTokens(s.documents[uri])})
+	}
+
+	// unknown requests get an error, unknown notifications are ignored
+	if msg.ID != nil {
+		return s.write(lspErrorResponse{"2.0", msg.ID, lspError{-32601, "Method not found: " + msg.Method}})
+	}
+	return nil
+}
+
+// analyze parses and checks a document, the last ast that parsed is kept so
+// completion keeps working while the document is being edited
+func (s *lspServer) analyze(uri string) []*Diagnostic {
+	path := uriToPath(uri)
+
+	parsed, err := Parse(NewFileTokenizer(path, s.documents[uri]))
+	if err != nil {
+		diagnostics, ok := AsDiagnostics(err)
+		if !ok {
+			diagnostics = []*Diagnostic{{
+				Kind:     "Syntax",
+				Severity: severityError,
+				Code:     "syntax-error",
+				Pos:      Position{path, 1, 1},
+				End:      Position{path, 1, 1},
+				Message:  err.Error(),
+			}}
+		}
+		return diagnostics
+	}
+	s.asts[uri] = parsed
+
+	diagnostics, _ := AsDiagnostics(errors.Join(Check(parsed)...))
+	diagnostics = append(diagnostics, Lint(parsed, s.project)...)
+	return diagnostics
+}
+
+func (s *lspServer) publishDiagnostics(uri string) error {
+	path := uriToPath(uri)
+	out := []lspDiagnostic{}
+
+	for _, d := range s.analyze(uri) {
+		severity := 1
+		if d.Severity == severityWarning {
+			severity = 2
+		}
+
+		// problems in imported files are shown at the top of the document
+		r := lspRange{toLspPosition(d.Pos), toLspPosition(d.End)}
+		message := d.Message
+		if d.Pos.File != path {
+			r = lspRange{}
+			message = fmt.Sprintf("%s: %s", d.Pos, d.Message)
+		}
+
+		out = append(out, lspDiagnostic{r, severity, d.Code, "sql-mi", message})
+	}
+
+	return s.write(lspNotification{"2.0", "textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": out,
+	}})
+}
+
+var attrPrefixRegex = regexp.MustCompile(`@[a-zA-Z0-9_.]*$`)
+
+func (s *lspServer) completion(uri string, pos lspPosition) []lspCompletionItem {
+	lines := strings.Split(s.documents[uri], "\n")
+	if pos.Line >= len(lines) {
+		return []lspCompletionItem{}
+	}
+
+	line := strings.TrimRight(lines[pos.Line], "\r")
+	if pos.Character > len(line) {
+		pos.Character = len(line)
+	}
+	prefix := line[:pos.Character]
+	tokens := documentTokens(prefix)
+	afterSpace := strings.HasSuffix(prefix, " ") || strings.HasSuffix(prefix, "\t")
+
+	if strings.Contains(prefix, "///") {
+		return []lspCompletionItem{}
+	}
+
+	// inside a string, only @reference arguments are completed
+	if strings.Count(prefix, "\"")%2 == 1 {
+		return s.referenceCompletion(uri, tokens)
+	}
+
+	if attrPrefixRegex.MatchString(prefix) {
+		return attributeCompletion()
+	}
+
+	n := len(tokens)
+	if isInsideTable(strings.Join(lines[:pos.Line], "\n")) {
+		if n == 0 || (n == 1 && !afterSpace) {
+			return keywordCompletion(T_END)
+		}
+		if (n == 1 && afterSpace) || (n == 2 && !afterSpace && tokens[1].TokenType == T_IDEN) {
+			return typeCompletion()
+		}
+		return []lspCompletionItem{}
+	}
+
+	if n == 0 || (n == 1 && !afterSpace) {
+		return keywordCompletion(T_TABLE, T_SET, T_IMPORT)
+	}
+
+	if tokens[0].TokenType == T_SET {
+		if (n == 1 && afterSpace) || (n == 2 && !afterSpace) {
+			items := []lspCompletionItem{}
+			for _, name := range configurable {
+				items = append(items, lspCompletionItem{Label: name, Kind: lspKindProperty})
+			}
+			return items
+		}
+		if tokens[1].Literal == "provider" && ((n == 2 && afterSpace) || (n == 3 && !afterSpace)) {
+			items := []lspCompletionItem{}
+			for _, p := range providers {
+				items = append(items, lspCompletionItem{Label: string(p), Kind: lspKindValue})
+			}
+			return items
+		}
+	}
+
+	return []lspCompletionItem{}
+}
+
+// referenceCompletion completes the table in @reference("| and the colmun
+// in @reference("users", "|
+func (s *lspServer) referenceCompletion(uri string, tokens []*Token) []lspCompletionItem {
+	items := []lspCompletionItem{}
+	parsed := s.asts[uri]
+	n := len(tokens)
+	if parsed == nil || n < 3 {
+		return items
+	}
+
+	if tokens[n-2].TokenType == T_LEFT_PAREN && isReferenceAttr(tokens[n-3]) {
+		for _, table := range parsed.Tables {
+			items = append(items, lspCompletionItem{Label: table.Name, Kind: lspKindStruct})
+		}
+		return items
+	}
+
+	if n >= 5 &&
+		tokens[n-2].TokenType == T_COMMA &&
+		tokens[n-3].TokenType == T_STRING &&
+		tokens[n-4].TokenType == T_LEFT_PAREN &&
+		isReferenceAttr(tokens[n-5]) {
+		table := findTable(parsed, tokens[n-3].Literal)
+		if table == nil {
+			return items
+		}
+		for _, colmun := range table.Colmuns {
+			items = append(items, lspCompletionItem{
+				Label:  colmun.Name,
+				Kind:   lspKindField,
+				Detail: formatColmunType(colmun),
+			})
+		}
+	}
+
+	return items
+}
+
+func attributeCompletion() []lspCompletionItem {
+	names := []string{}
+	for name := range parseAttrFuncMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := []lspCompletionItem{}
+	for _, name := range names {
+		items = append(items, lspCompletionItem{
+			Label:         name,
+			Kind:          lspKindProperty,
+			Documentation: attributeDocs[name],
+		})
+	}
+
+	for _, p := range providers {
+		items = append(items, lspCompletionItem{
+			Label:         "db." + string(p),
+			Kind:          lspKindProperty,
+			Documentation: attributeDocs["db"],
+		})
+	}
+
+	return items
+}
+
+func keywordCompletion(keywords ...string) []lspCompletionItem {
+	items := []lspCompletionItem{}
+	for _, keyword := range keywords {
+		items = append(items, lspCompletionItem{
+			Label:         keyword,
+			Kind:          lspKindKeyword,
+			Documentation: keywordDocs[keyword],
+		})
+	}
+	return items
+}
+
+func typeCompletion() []lspCompletionItem {
+	items := []lspCompletionItem{}
+	for _, name := range typeNames() {
+		items = append(items, lspCompletionItem{
+			Label:  name,
+			Kind:   lspKindType,
+			Detail: typeDetail(name),
+		})
+	}
+	return items
+}
+
+// typeNames lists the logical types, parameterized ones included
+func typeNames() []string {
+	names := []string{}
+	for name := range types {
+		names = append(names, name)
+	}
+	for name := range paramTypes {
+		if _, exists := types[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func typeDetail(name string) string {
+	mapping := types[name]
+	if mapping == nil {
+		mapping = paramTypes[name].Types
+	}
+
+	parts := []string{}
+	for _, p := range providers {
+		parts = append(parts, fmt.Sprintf("%s: %s", p, mapping[p]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (s *lspServer) hover(uri string, pos lspPosition) interface{} {
+	tokens := documentTokens(s.documents[uri])
+	index := tokenAt(tokens, pos)
+	if index < 0 {
+		return nil
+	}
+	tok := tokens[index]
+
+	doc := ""
+	switch tok.TokenType {
+	case T_ATTR:
+		name := tok.Literal
+		if strings.HasPrefix(name, "db.") {
+			name = "db"
+		}
+		doc = fmt.Sprintf("**@%s**\n\n%s", tok.Literal, attributeDocs[name])
+	case T_TABLE, T_END, T_SET, T_IMPORT:
+		doc = keywordDocs[tok.Literal]
+	case T_IDEN:
+		_, isType := types[tok.Literal]
+		_, isParamType := paramTypes[tok.Literal]
+		if isType || isParamType {
+			doc = fmt.Sprintf("**%s**\n\n%s", tok.Literal, typeDetail(tok.Literal))
+		}
+	}
+
+	if len(doc) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": doc},
+		"range":    tokenRange(tok),
+	}
+}
+
+// definition jumps from the arguments of @reference to the table or colmun
+// they point at
+func (s *lspServer) definition(uri string, pos lspPosition) interface{} {
+	parsed := s.asts[uri]
+	tokens := documentTokens(s.documents[uri])
+	i := tokenAt(tokens, pos)
+	if parsed == nil || i < 0 || tokens[i].TokenType != T_STRING {
+		return nil
+	}
+
+	if i >= 2 && tokens[i-1].TokenType == T_LEFT_PAREN && isReferenceAttr(tokens[i-2]) {
+		table := findTable(parsed, tokens[i].Literal)
+		if table != nil {
+			return nodeLocation(table.Pos, table.Name)
+		}
+	}
+
+	if i >= 4 &&
+		tokens[i-1].TokenType == T_COMMA &&
+		tokens[i-2].TokenType == T_STRING &&
+		tokens[i-3].TokenType == T_LEFT_PAREN &&
+		isReferenceAttr(tokens[i-4]) {
+		table := findTable(parsed, tokens[i-2].Literal)
+		if table == nil {
+			return nil
+		}
+		colmun := findColmun(table, tokens[i].Literal)
+		if colmun != nil {
+			return nodeLocation(colmun.Pos, colmun.Name)
+		}
+	}
+
+	return nil
+}
+
+func (s *lspServer) formatting(uri string) interface{} {
+	text := s.documents[uri]
+
+	parsed, err := Parse(NewFileTokenizer(uriToPath(uri), text))
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	return []map[string]interface{}{{
+		"range":   lspRange{lspPosition{0, 0}, lspPosition{len(lines), 0}},
+		"newText": Format(parsed),
+	}}
+}
+
+// documentTokens tokenizes a whole text, line ends are left out
+func documentTokens(text string) []*Token {
+	tokens := []*Token{}
+	t := NewTokenizer(text)
+	for tok := t.NextToken(); tok.TokenType != T_EOF; tok = t.NextToken() {
+		if tok.TokenType != T_EOL {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+func isInsideTable(text string) bool {
+	inside := false
+	for _, tok := range documentTokens(text) {
+		if tok.TokenType == T_TABLE {
+			inside = true
+		} else if tok.TokenType == T_END {
+			inside = false
+		}
+	}
+	return inside
+}
+
+func isReferenceAttr(tok *Token) bool {
+	return tok.TokenType == T_ATTR && tok.Literal == "reference"
+}
+
+func tokenAt(tokens []*Token, pos lspPosition) int {
+	for i, tok := range tokens {
+		if tok.Line == pos.Line+1 && tok.Col <= pos.Character+1 && pos.Character+1 < tok.End() {
+			return i
+		}
+	}
+	return -1
+}
+
+func tokenRange(tok *Token) lspRange {
+	return lspRange{
+		lspPosition{tok.Line - 1, tok.Col - 1},
+		lspPosition{tok.Line - 1, tok.End() - 1},
+	}
+}
+
+func nodeLocation(pos Position, name string) lspLocation {
+	start := toLspPosition(pos)
+	end := lspPosition{start.Line, start.Character + len(name)}
+	return lspLocation{pathToURI(pos.File), lspRange{start, end}}
+}
+
+func toLspPosition(pos Position) lspPosition {
+	line, col := pos.Line-1, pos.Col-1
+	if line < 0 {
+		line = 0
+	}
+	if col < 0 {
+		col = 0
+	}
+	return lspPosition{line, col}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
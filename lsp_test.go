@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lspFrame frames the json-rpc messages the way a client sends them
+func lspFrame(messages ...string) string {
+	framed := ""
+	for _, message := range messages {
+		framed += fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(message), message)
+	}
+	return framed
+}
+
+// lspReplies splits what the server wrote into its messages
+func lspReplies(t *testing.T, out string) []map[string]interface{} {
+	t.Helper()
+
+	in := bufio.NewReader(strings.NewReader(out))
+	replies := []map[string]interface{}{}
+	for {
+		header, err := in.ReadString('\n')
+		if err == io.EOF {
+			return replies
+		}
+		in.ReadString('\n')
+
+		length := 0
+		_, err = fmt.Sscanf(header, "Content-Length: %d", &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := make([]byte, length)
+		io.ReadFull(in, body)
+
+		reply := map[string]interface{}{}
+		err = json.Unmarshal(body, &reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+// openDocument starts a server with one open document
+func openDocument(t *testing.T, text string) (*lspServer, string) {
+	t.Helper()
+
+	initValues()
+	initParseAttrFuncs()
+
+	uri := pathToURI(filepath.Join(t.TempDir(), "schema.sqmi"))
+	server := &lspServer{
+		out:       &bytes.Buffer{},
+		documents: map[string]string{},
+		asts:      map[string]*AST{},
+		project:   &ProjectConfig{Migrations: t.TempDir()},
+	}
+	server.documents[uri] = text
+	server.publishDiagnostics(uri)
+	return server, uri
+}
+
+func TestRunLSP(t *testing.T) {
+	uri := pathToURI(filepath.Join(t.TempDir(), "schema.sqmi"))
+	document, _ := json.Marshal("set provider sqlite\ntable users\n\tid int @id\n\tname strin\nend\n")
+
+	in := lspFrame(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"`+uri+`","text":`+string(document)+`}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"workspace/symbol","params":{}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	out := &bytes.Buffer{}
+
+	err := RunLSP(strings.NewReader(in), out, &ProjectConfig{Migrations: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := lspReplies(t, out.String())
+	if len(replies) != 4 {
+		t.Fatalf("%d replies, want 4:\n%s", len(replies), out)
+	}
+
+	initialize, _ := json.Marshal(replies[0])
+	if !strings.Contains(string(initialize), `"hoverProvider":true`) {
+		t.Errorf("initialize does not announce hover:\n%s", initialize)
+	}
+
+	diagnostics, _ := json.Marshal(replies[1]["params"])
+	want := `{"diagnostics":[{"code":"unknown-type","message":"Unknown data type 'strin' for colmun 'name'","range":{"end":{"character":5,"line":3},"start":{"character":1,"line":3}},"severity":1,"source":"sql-mi"}],"uri":"` + uri + `"}`
+	if replies[1]["method"] != "textDocument/publishDiagnostics" || string(diagnostics) != want {
+		t.Errorf("diagnostics %s, want %s", diagnostics, want)
+	}
+
+	unknown, _ := json.Marshal(replies[2]["error"])
+	if string(unknown) != `{"code":-32601,"message":"Method not found: workspace/symbol"}` {
+		t.Errorf("unknown method replied %s", unknown)
+	}
+}
+
+func TestRunLSPErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		error string
+	}{
+		{"closed connection", "", "Client closed the connection"},
+		{"exit without shutdown", lspFrame(`{"jsonrpc":"2.0","method":"exit"}`), "Exit without shutdown"},
+		{"missing header", "\r\n{}", "Missing Content-Length header"},
+		{"bad header", "Content-Length: ten\r\n\r\n", "Bad Content-Length"},
+		{"bad message", lspFrame(`{"id":`), "Bad message"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := RunLSP(strings.NewReader(test.in), &bytes.Buffer{}, &ProjectConfig{})
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+func TestLSPCompletion(t *testing.T) {
+	schema := "set provider sqlite\ntable users\n\tid int @id\n\tname string\nend\n"
+
+	tests := []struct {
+		name   string
+		line   string
+		labels []string
+	}{
+		{"top level keyword", "ta", []string{"table", "set", "import"}},
+		{"set option", "set ", []string{"provider", "url"}},
+		{"provider", "set provider ", []string{"sqlite", "postgresql", "mysql"}},
+		{"reference table", "\tauthor int @reference(\"", []string{"users"}},
+		{"reference colmun", "\tauthor int @reference(\"users\", \"", []string{"id", "name"}},
+		{"reference to an unknown table", "\tauthor int @reference(\"tags\", \"", []string{}},
+		{"comment", "/// a note ", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, uri := openDocument(t, schema)
+
+			// the last ast that parsed is used while the document is edited
+			text := schema + "table posts\n" + test.line
+			if !strings.HasPrefix(test.line, "\t") {
+				text = schema + test.line
+			}
+			server.documents[uri] = text
+			server.publishDiagnostics(uri)
+
+			lines := strings.Split(text, "\n")
+			items := server.completion(uri, lspPosition{len(lines) - 1, len(test.line)})
+
+			labels := []string{}
+			for _, item := range items {
+				labels = append(labels, item.Label)
+			}
+			if !reflect.DeepEqual(labels, test.labels) {
+				t.Errorf("completed %q, want %q", labels, test.labels)
+			}
+		})
+	}
+}
+
+func TestLSPCompletionInsideTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		label string
+	}{
+		{"end of the table", "\te", "end"},
+		{"type", "\tname ", "string"},
+		{"parameterized type", "\tprice dec", "decimal"},
+		{"attribute", "\tname string @", "unique"},
+		{"provider override", "\tdata json @db.", "db.postgresql"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, uri := openDocument(t, "table users\n"+test.line)
+
+			found := false
+			for _, item := range server.completion(uri, lspPosition{1, len(test.line)}) {
+				found = found || item.Label == test.label
+			}
+			if !found {
+				t.Errorf("%q not completed", test.label)
+			}
+		})
+	}
+}
+
+func TestLSPHover(t *testing.T) {
+	server, uri := openDocument(t, "set provider sqlite\ntable users\n\tid int @id\nend\n")
+
+	tests := []struct {
+		name     string
+		position lspPosition
+		contains string
+	}{
+		{"keyword", lspPosition{1, 2}, "Starts a table"},
+		{"type", lspPosition{2, 5}, "postgresql: INTEGER"},
+		{"attribute", lspPosition{2, 9}, "primary key"},
+		{"table name", lspPosition{1, 8}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hover := server.hover(uri, test.position)
+			if len(test.contains) == 0 {
+				if hover != nil {
+					t.Errorf("hover %v, want none", hover)
+				}
+				return
+			}
+
+			content, _ := json.Marshal(hover)
+			if !strings.Contains(string(content), test.contains) {
+				t.Errorf("hover %s does not contain %q", content, test.contains)
+			}
+		})
+	}
+}
+
+func TestLSPDefinition(t *testing.T) {
+	server, uri := openDocument(t, "set provider sqlite\ntable users\n\tid int @id\nend\ntable posts\n\tauthor int @reference(\"users\", \"id\")\nend\n")
+
+	tests := []struct {
+		name     string
+		position lspPosition
+		location *lspLocation
+	}{
+		{"table", lspPosition{5, 25}, &lspLocation{uri, lspRange{lspPosition{1, 6}, lspPosition{1, 11}}}},
+		{"colmun", lspPosition{5, 33}, &lspLocation{uri, lspRange{lspPosition{2, 1}, lspPosition{2, 3}}}},
+		{"not a reference", lspPosition{5, 3}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definition := server.definition(uri, test.position)
+			if test.location == nil {
+				if definition != nil {
+					t.Errorf("definition %v, want none", definition)
+				}
+				return
+			}
+			if !reflect.DeepEqual(definition, *test.location) {
+				t.Errorf("definition %+v, want %+v", definition, *test.location)
+			}
+		})
+	}
+}
+
+func TestLSPFormatting(t *testing.T) {
+	server, uri := openDocument(t, "set provider sqlite\ntable users\n\tid int @id\n\tname    string\nend\n")
+
+	edits, _ := json.Marshal(server.formatting(uri))
+	want := `[{"newText":"set provider sqlite\n\ntable users\n\tid   int    @id\n\tname string\nend\n","range":{"start":{"line":0,"character":0},"end":{"line":6,"character":0}}}]`
+	if string(edits) != want {
+		t.Errorf("edits %s, want %s", edits, want)
+	}
+
+	server.documents[uri] = "table users\n\tid int @\n"
+	if edits := server.formatting(uri); edits != nil {
+		t.Errorf("edits %v for a document that does not parse", edits)
+	}
+}
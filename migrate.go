@@ -0,0 +1,298 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// migrations live in <dir>/<timestamp>_<name>/ with an up.sql, a down.sql and
+// the schema.json they lead to, which the next migration is diffed against
+const (
+	migrationsTable    = "_sqlmi_migrations"
+	migrationUp        = "up.sql"
+	migrationDown      = "down.sql"
+	migrationSnapshot  = "schema.json"
+	migrationTimestamp = "20060102150405"
+)
+
+var migrationNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+type Migration struct {
+	Name string
+	Path string
+	SQL  string
+}
+
+// Checksum is the sha256 of the up migration, an applied migration must not
+// change anymore
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.SQL))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateMigration writes the migration from the last snapshot in dir to ast
+// and returns its directory, or an empty string when nothing changed
+func CreateMigration(dir string, name string, ast *AST, now time.Time) (string, error) {
+	if !migrationNameRegex.MatchString(name) {
+		return "", fmt.Errorf("Error: Invalid migration name '%s', use letters, digits, _ and -", name)
+	}
+
+	previous, err := latestSnapshot(dir, ast.Configuration["provider"])
+	if err != nil {
+		return "", err
+	}
+
+	up, err := GenerateMigration(previous, ast)
+	if err != nil {
+		return "", err
+	}
+	if len(up) == 0 {
+		return "", nil
+	}
+
+	down, err := GenerateMigration(ast, previous)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot, err := snapshotJSON(ast)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, migrationTime(dir, now).Format(migrationTimestamp)+"_"+name)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("Error: Migration '%s' already exists", path)
+	}
+
+	files := map[string]string{migrationUp: up, migrationDown: down, migrationSnapshot: snapshot}
+	for _, file := range sortedKeys(files) {
+		err = writeOutput(filepath.Join(path, file), files[file])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+// migrationTime gives the time a new migration in dir is named after. It comes
+// after every migration already there, so migrations created within the same
+// second still sort in the order they were created
+func migrationTime(dir string, now time.Time) time.Time {
+	now = now.UTC().Truncate(time.Second)
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		timestamp, _, _ := strings.Cut(entry.Name(), "_")
+		created, err := time.Parse(migrationTimestamp, timestamp)
+		if err == nil && !now.After(created) {
+			now = created.Add(time.Second)
+		}
+	}
+	return now
+}
+
+// snapshotJSON writes the ast without source positions and files, so editing
+// the layout of a schema does not change the snapshots of its migrations
+func snapshotJSON(ast *AST) (string, error) {
+	content, err := FormatASTJSON(ast)
+	if err != nil {
+		return "", err
+	}
+
+	var tree map[string]interface{}
+	err = json.Unmarshal([]byte(content), &tree)
+	if err != nil {
+		return "", err
+	}
+	delete(tree, "files")
+	withoutPositions(tree)
+
+	stripped, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(stripped) + "\n", nil
+}
+
+func withoutPositions(node interface{}) {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		delete(value, "pos")
+		for _, child := range value {
+			withoutPositions(child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			withoutPositions(child)
+		}
+	}
+}
+
+// latestSnapshot reads the schema of the last migration, without migrations
+// the database is empty
+func latestSnapshot(dir string, provider string) (*AST, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Error reading migrations: %s", err)
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		path := filepath.Join(dir, entries[i].Name(), migrationSnapshot)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		return ReadASTJSON(content, path)
+	}
+
+	return &AST{map[string]string{"provider": provider}, []*TabelAST{}, []string{}, []*SettingAST{}, []*ImportAST{}}, nil
+}
+
+// FindMigrations lists the migrations at the paths in the order they are
+// applied. A path is a migration directory, a directory of migrations or a
+// plain .sql file
+func FindMigrations(paths []string) ([]*Migration, error) {
+	migrations := []*Migration{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Migrations '%s' do not exist", path)
+		}
+
+		if !info.IsDir() || isMigrationDir(path) {
+			migration, err := readMigration(path)
+			if err != nil {
+				return nil, err
+			}
+			migrations = append(migrations, migration)
+			continue
+		}
+
+		// entries come sorted by name, so by timestamp
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading migrations: %s", err)
+		}
+		for _, entry := range entries {
+			entryPath := filepath.Join(path, entry.Name())
+			if (entry.IsDir() && !isMigrationDir(entryPath)) || (!entry.IsDir() && filepath.Ext(entry.Name()) != ".sql") {
+				continue
+			}
+
+			migration, err := readMigration(entryPath)
+			if err != nil {
+				return nil, err
+			}
+			migrations = append(migrations, migration)
+		}
+	}
+
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("Error: No migrations found")
+	}
+	return migrations, nil
+}
+
+func isMigrationDir(path string) bool {
+	info, err := os.Stat(filepath.Join(path, migrationUp))
+	return err == nil && !info.IsDir()
+}
+
+func readMigration(path string) (*Migration, error) {
+	name := filepath.Base(path)
+	file := path
+	if isMigrationDir(path) {
+		file = filepath.Join(path, migrationUp)
+	} else {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %s", err)
+	}
+	return &Migration{name, file, string(content)}, nil
+}
+
+// ApplyMigrations applies the migrations the database does not have yet, each
+// in its own transaction together with its row in the history. Nothing is
+// applied when an applied migration was edited
+func ApplyMigrations(db *sql.DB, migrations []*Migration) ([]*Migration, error) {
+	_, err := db.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY NOT NULL, checksum TEXT NOT NULL, applied_at TEXT DEFAULT CURRENT_TIMESTAMP NOT NULL)",
+		migrationsTable,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not create the %s table: %v", migrationsTable, err)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []*Migration{}
+	for _, migration := range migrations {
+		checksum, exists := applied[migration.Name]
+		if !exists {
+			pending = append(pending, migration)
+			continue
+		}
+		if checksum != migration.Checksum() {
+			return nil, fmt.Errorf("Error: Migration '%s' was edited after it was applied, create a new migration instead", migration.Name)
+		}
+	}
+
+	done := []*Migration{}
+	for _, migration := range pending {
+		err = inTransaction(db, func(tx *sql.Tx) error {
+			err := execStatements(tx, splitStatements(migration.SQL))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(
+				fmt.Sprintf("INSERT INTO %s (name, checksum) VALUES (?, ?)", migrationsTable),
+				migration.Name,
+				migration.Checksum(),
+			)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("Error: Migration '%s' failed, it was not applied\n%v", migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func appliedMigrations(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name, checksum FROM %s", migrationsTable))
+	if err != nil {
+		return nil, fmt.Errorf("Error: Could not read the applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[string]string{}
+	for rows.Next() {
+		name, checksum := "", ""
+		err = rows.Scan(&name, &checksum)
+		if err != nil {
+			return nil, fmt.Errorf("Error: Could not read the applied migrations: %v", err)
+		}
+		applied[name] = checksum
+	}
+	return applied, rows.Err()
+}
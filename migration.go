@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerateMigration writes the sql that changes a database created from
+// oldAst into one matching newAst, the down migration is the same call with
+// the schemas swapped. What sql can not express is written as a comment to be
+// done by hand
+func GenerateMigration(oldAst *AST, newAst *AST) (string, error) {
+	target := newAst.Configuration["provider"]
+	if previous := oldAst.Configuration["provider"]; len(oldAst.Tables) > 0 && previous != target {
+		return "", fmt.Errorf("Error: The provider changed from '%s' to '%s', a migration can not change it", previous, target)
+	}
+
+	initValues()
+	if !isProviderAvailable(target) {
+		return "", fmt.Errorf("Error: Provider '%s' not supported", target)
+	}
+	provider = Provider(target)
+
+	diff := Diff(oldAst, newAst)
+	statements := []string{}
+
+	for _, table := range inReferenceOrder(newAst, diff.AddedTables) {
+		create, err := generateTableSQL(table)
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, create)
+	}
+
+	for _, tableDiff := range diff.ChangedTables {
+		statements = append(statements, renameSQL(tableDiff)...)
+		if !tableDiff.HasColmunChanges() {
+			continue
+		}
+
+		var changes []string
+		var err error
+		if provider == sqlite {
+			changes, err = rebuildTableSQL(tableDiff)
+		} else {
+			changes, err = alterTableSQL(tableDiff)
+		}
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, changes...)
+	}
+
+	// tables referencing others are dropped first
+	dropped := inReferenceOrder(oldAst, diff.DroppedTables)
+	for i := len(dropped) - 1; i >= 0; i-- {
+		statements = append(statements, fmt.Sprintf("DROP TABLE %s;", quoteName(dropped[i].Name)))
+	}
+
+	if len(statements) == 0 {
+		return "", nil
+	}
+	return strings.Join(statements, "\n\n") + "\n", nil
+}
+
+// inReferenceOrder gives the tables of ast that are in tables, referenced
+// tables before the tables referencing them
+func inReferenceOrder(ast *AST, tables []*TabelAST) []*TabelAST {
+	wanted := map[*TabelAST]bool{}
+	for _, table := range tables {
+		wanted[table] = true
+	}
+
+	sorted, _ := sortTablesByReferences(ast)
+	ordered := []*TabelAST{}
+	for _, table := range sorted {
+		if wanted[table] {
+			ordered = append(ordered, table)
+		}
+	}
+	return ordered
+}
+
+// renameSQL renames a table and its colmuns in place, so their rows are kept
+func renameSQL(d *TableDiff) []string {
+	statements := []string{}
+	if d.IsRenamed() {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", quoteName(d.Old.Name), quoteName(d.New.Name)))
+	}
+
+	for _, colDiff := range d.ChangedColmuns {
+		if colDiff.Old.Name != colDiff.New.Name {
+			statements = append(statements, fmt.Sprintf(
+				"ALTER TABLE %s RENAME COLUMN %s TO %s;",
+				quoteName(d.New.Name),
+				quoteName(colDiff.Old.Name),
+				quoteName(colDiff.New.Name),
+			))
+		}
+	}
+
+	if provider == postgresql {
+		statements = append(statements, renameConstraintsSQL(d)...)
+	}
+	return statements
+}
+
+// renameConstraintsSQL keeps the names postgresql gave the constraints of a
+// renamed table or colmun, it does not rename them by itself and later
+// migrations find them by these names
+func renameConstraintsSQL(d *TableDiff) []string {
+	statements := []string{}
+	rename := func(oldName string, newName string) {
+		if oldName != newName {
+			statements = append(statements, fmt.Sprintf(
+				"ALTER TABLE %s RENAME CONSTRAINT %s TO %s;",
+				quoteName(d.New.Name),
+				oldName,
+				newName,
+			))
+		}
+	}
+
+	dropped := map[*ColmunAST]bool{}
+	for _, colmun := range d.DroppedColmuns {
+		dropped[colmun] = true
+	}
+
+	for _, oldCol := range d.Old.Colmuns {
+		if dropped[oldCol] {
+			continue
+		}
+		name := oldCol.Name
+		for _, colDiff := range d.ChangedColmuns {
+			if colDiff.Old == oldCol {
+				name = colDiff.New.Name
+			}
+		}
+
+		if _, exists := (*oldCol.Attributes)["id"]; exists {
+			rename(d.Old.Name+"_pkey", d.New.Name+"_pkey")
+		}
+		if _, exists := (*oldCol.Attributes)["unique"]; exists {
+			rename(d.Old.Name+"_"+oldCol.Name+"_key", d.New.Name+"_"+name+"_key")
+		}
+		if findReference(d.Old, oldCol.Name) != nil {
+			rename(d.Old.Name+"_"+oldCol.Name+"_fkey", d.New.Name+"_"+name+"_fkey")
+		}
+	}
+	return statements
+}
+
+// rebuildTableSQL changes a sqlite table by copying its rows into a new table,
+// sqlite can not alter the colmuns of a table in place. Renames are done
+// before, so the table and its colmuns already have their new names
+func rebuildTableSQL(d *TableDiff) ([]string, error) {
+	temp := &TabelAST{"_sqlmi_new_" + d.New.Name, d.New.Colmuns, d.New.References, &AttributesAST{}, d.New.Pos, d.New.Doc}
+	create, err := generateTableSQL(temp)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := []string{create}
+
+	added := map[*ColmunAST]bool{}
+	for _, colmun := range d.AddedColmuns {
+		added[colmun] = true
+	}
+
+	kept := []string{}
+	for _, colmun := range d.New.Colmuns {
+		if !added[colmun] {
+			kept = append(kept, quoteName(colmun.Name))
+		}
+	}
+	if len(kept) > 0 {
+		colmuns := strings.Join(kept, ", ")
+		statements = append(statements, fmt.Sprintf(
+			"INSERT INTO %s (%s) SELECT %s FROM %s;",
+			temp.Name,
+			colmuns,
+			colmuns,
+			quoteName(d.New.Name),
+		))
+	}
+
+	return append(
+		statements,
+		fmt.Sprintf("DROP TABLE %s;", quoteName(d.New.Name)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", temp.Name, quoteName(d.New.Name)),
+	), nil
+}
+
+// alterTableSQL changes a postgresql or mysql table colmun by colmun
+func alterTableSQL(d *TableDiff) ([]string, error) {
+	table := quoteName(d.New.Name)
+	statements := []string{}
+
+	for _, colmun := range d.AddedColmuns {
+		definition, err := handleColmun(colmun, table)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, definition))
+
+		if ref := findReference(d.New, colmun.Name); ref != nil {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s;", table, handleRef(ref)))
+		}
+	}
+
+	for _, colDiff := range d.ChangedColmuns {
+		changes, err := alterColmunSQL(d, colDiff)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, changes...)
+	}
+
+	for _, colmun := range d.DroppedColmuns {
+		if provider == mysql && findReference(d.Old, colmun.Name) != nil {
+			statements = append(statements, manualChange(d.New.Name, colmun.Name, "drop its foreign key before the colmun"))
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, quoteName(colmun.Name)))
+	}
+
+	return statements, nil
+}
+
+func alterColmunSQL(d *TableDiff, colDiff *ColmunDiff) ([]string, error) {
+	table, name := d.New.Name, colDiff.New.Name
+	quotedTable, quotedName := quoteName(table), quoteName(name)
+	oldCol, newCol := colDiff.Old, colDiff.New
+	statements := []string{}
+
+	oldType, err := getType(oldCol)
+	if err != nil {
+		return nil, err
+	}
+	newType, err := getType(newCol)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := func(attr string) bool {
+		return attributeSQL(oldCol, attr) != attributeSQL(newCol, attr)
+	}
+
+	if changed("id") {
+		statements = append(statements, manualChange(table, name, "change the primary key"))
+	}
+
+	switch provider {
+	case postgresql:
+		alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", quotedTable, quotedName)
+		if oldType != newType {
+			statements = append(statements, alter+fmt.Sprintf("TYPE %s;", newType))
+		}
+		if changed("nullable") && isNullable(newCol) {
+			statements = append(statements, alter+"DROP NOT NULL;")
+		} else if changed("nullable") {
+			statements = append(statements, alter+"SET NOT NULL;")
+		}
+		if changed("default") && len(attributeSQL(newCol, "default")) > 0 {
+			statements = append(statements, alter+"SET "+attributeSQL(newCol, "default")+";")
+		} else if changed("default") {
+			statements = append(statements, alter+"DROP DEFAULT;")
+		}
+		if changed("auto_increment") && len(attributeSQL(newCol, "auto_increment")) > 0 {
+			statements = append(statements, alter+"ADD GENERATED BY DEFAULT AS IDENTITY;")
+		} else if changed("auto_increment") {
+			statements = append(statements, alter+"DROP IDENTITY;")
+		}
+		if changed("unique") && len(attributeSQL(newCol, "unique")) > 0 {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s);", quotedTable, quotedName))
+		} else if changed("unique") {
+			// the name postgresql gives unnamed unique constraints
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s_%s_key;", quotedTable, table, name))
+		}
+	case mysql:
+		if oldType != newType || changed("nullable") || changed("default") || changed("auto_increment") {
+			// the key and unique index stay as they are
+			definition, err := handleColmun(withoutAttributes(newCol, "id", "unique"), table)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", quotedTable, definition))
+		}
+		if changed("unique") && len(attributeSQL(newCol, "unique")) > 0 {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s);", quotedTable, quotedName))
+		} else if changed("unique") {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP INDEX %s;", quotedTable, quotedName))
+		}
+	}
+
+	// a reference to a renamed table or colmun follows the rename by itself
+	oldRef, newRef := findReference(d.Old, oldCol.Name), findReference(d.New, name)
+	refChanged := hasChange(colDiff, "reference", "onDelete", "onUpdate")
+	if oldRef != nil && refChanged {
+		if provider == postgresql {
+			// the name postgresql gives unnamed foreign keys
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s_%s_fkey;", quotedTable, table, name))
+		} else {
+			statements = append(statements, manualChange(table, name, "drop its foreign key"))
+		}
+	}
+	if newRef != nil && refChanged {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s;", quotedTable, handleRef(newRef)))
+	}
+
+	return statements, nil
+}
+
+// attributeSQL gives what an attribute adds to the colmun definition, empty
+// when the colmun does not have it
+func attributeSQL(colmun *ColmunAST, name string) string {
+	attr, exists := (*colmun.Attributes)[name]
+	if !exists {
+		return ""
+	}
+	sql, err := handleAttr(boolDefault(colmun, attr))
+	if err != nil {
+		return ""
+	}
+	return sql
+}
+
+func withoutAttributes(colmun *ColmunAST, names ...string) *ColmunAST {
+	attributes := AttributesAST{}
+	for name, attr := range *colmun.Attributes {
+		attributes[name] = attr
+	}
+	for _, name := range names {
+		delete(attributes, name)
+	}
+
+	copied := *colmun
+	copied.Attributes = &attributes
+	return &copied
+}
+
+// hasChange tells whether one of the given properties of a colmun changed
+func hasChange(colDiff *ColmunDiff, keys ...string) bool {
+	for _, change := range colDiff.Changes {
+		for _, key := range keys {
+			if strings.HasPrefix(change, key+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func findReference(table *TabelAST, colmun string) *ReferenceAST {
+	for _, ref := range table.References {
+		if ref.SourceCol == colmun {
+			return ref
+		}
+	}
+	return nil
+}
+
+func manualChange(table string, colmun string, change string) string {
+	return fmt.Sprintf("-- TODO %s.%s: %s by hand", table, colmun, change)
+}
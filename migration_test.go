@@ -0,0 +1,339 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const migrationBase = `
+table users
+	id int @id
+	name string
+end
+`
+
+func parseSchema(t *testing.T, provider string, schema string) *AST {
+	t.Helper()
+
+	parsed, err := Parse(NewTokenizer("set provider " + provider + "\n" + schema))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return parsed
+}
+
+func sqliteRebuild(colmuns string, kept string) string {
+	return "CREATE TABLE _sqlmi_new_users (\n" + colmuns + "\n);\n\n" +
+		"INSERT INTO _sqlmi_new_users (" + kept + ") SELECT " + kept + " FROM users;\n\n" +
+		"DROP TABLE users;\n\n" +
+		"ALTER TABLE _sqlmi_new_users RENAME TO users;\n"
+}
+
+func TestGenerateMigration(t *testing.T) {
+	added := `
+table users
+	id int @id
+	name string
+	email string @nullable
+end
+`
+	dropped := `
+table users
+	id int @id
+end
+`
+	renamedColmun := `
+table users
+	id int @id
+	full_name string @renamedFrom("name")
+end
+`
+	renamedTable := `
+table people @renamedFrom("users")
+	id int @id
+	name string
+end
+`
+	changedType := `
+table users
+	id int @id
+	name string(100)
+end
+`
+	addedTable := migrationBase + `
+table posts
+	id int @id
+	author int @reference("users", "id")
+end
+`
+
+	tests := []struct {
+		name     string
+		provider string
+		schema   string
+		up       string
+		down     string
+	}{
+		{
+			"add colmun", "sqlite", added,
+			sqliteRebuild("\tid INTEGER PRIMARY KEY NOT NULL,\n\tname TEXT NOT NULL,\n\temail TEXT NULL", "id, name"),
+			sqliteRebuild("\tid INTEGER PRIMARY KEY NOT NULL,\n\tname TEXT NOT NULL", "id, name"),
+		},
+		{
+			"add colmun", "postgresql", added,
+			"ALTER TABLE users ADD COLUMN email TEXT NULL;\n",
+			"ALTER TABLE users DROP COLUMN email;\n",
+		},
+		{
+			"add colmun", "mysql", added,
+			"ALTER TABLE users ADD COLUMN email VARCHAR(255) NULL;\n",
+			"ALTER TABLE users DROP COLUMN email;\n",
+		},
+		{
+			"drop colmun", "sqlite", dropped,
+			sqliteRebuild("\tid INTEGER PRIMARY KEY NOT NULL", "id"),
+			sqliteRebuild("\tid INTEGER PRIMARY KEY NOT NULL,\n\tname TEXT NOT NULL", "id"),
+		},
+		{
+			"drop colmun", "postgresql", dropped,
+			"ALTER TABLE users DROP COLUMN name;\n",
+			"ALTER TABLE users ADD COLUMN name TEXT NOT NULL;\n",
+		},
+		{
+			"drop colmun", "mysql", dropped,
+			"ALTER TABLE users DROP COLUMN name;\n",
+			"ALTER TABLE users ADD COLUMN name VARCHAR(255) NOT NULL;\n",
+		},
+		{
+			"rename colmun", "sqlite", renamedColmun,
+			"ALTER TABLE users RENAME COLUMN name TO full_name;\n",
+			"ALTER TABLE users RENAME COLUMN full_name TO name;\n",
+		},
+		{
+			"rename colmun", "postgresql", renamedColmun,
+			"ALTER TABLE users RENAME COLUMN name TO full_name;\n",
+			"ALTER TABLE users RENAME COLUMN full_name TO name;\n",
+		},
+		{
+			"rename colmun", "mysql", renamedColmun,
+			"ALTER TABLE users RENAME COLUMN name TO full_name;\n",
+			"ALTER TABLE users RENAME COLUMN full_name TO name;\n",
+		},
+		{
+			"rename table", "sqlite", renamedTable,
+			"ALTER TABLE users RENAME TO people;\n",
+			"ALTER TABLE people RENAME TO users;\n",
+		},
+		{
+			"rename table", "postgresql", renamedTable,
+			"ALTER TABLE users RENAME TO people;\n\nALTER TABLE people RENAME CONSTRAINT users_pkey TO people_pkey;\n",
+			"ALTER TABLE people RENAME TO users;\n\nALTER TABLE users RENAME CONSTRAINT people_pkey TO users_pkey;\n",
+		},
+		{
+			"rename table", "mysql", renamedTable,
+			"ALTER TABLE users RENAME TO people;\n",
+			"ALTER TABLE people RENAME TO users;\n",
+		},
+		{
+			"change type", "sqlite", changedType,
+			sqliteRebuild("\tid INTEGER PRIMARY KEY NOT NULL,\n\tname TEXT NOT NULL", "id, name"),
+			sqliteRebuild("\tid INTEGER PRIMARY KEY NOT NULL,\n\tname TEXT NOT NULL", "id, name"),
+		},
+		{
+			"change type", "postgresql", changedType,
+			"ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(100);\n",
+			"ALTER TABLE users ALTER COLUMN name TYPE TEXT;\n",
+		},
+		{
+			"change type", "mysql", changedType,
+			"ALTER TABLE users MODIFY COLUMN name VARCHAR(100) NOT NULL;\n",
+			"ALTER TABLE users MODIFY COLUMN name VARCHAR(255) NOT NULL;\n",
+		},
+		{
+			"add table", "postgresql", addedTable,
+			"CREATE TABLE posts (\n\tid INTEGER PRIMARY KEY NOT NULL,\n\tauthor INTEGER NOT NULL,\n\tFOREIGN KEY (author) REFERENCES users(id)\n);\n",
+			"DROP TABLE posts;\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.provider+"/"+test.name, func(t *testing.T) {
+			oldAst := parseSchema(t, test.provider, migrationBase)
+			newAst := parseSchema(t, test.provider, test.schema)
+
+			up, err := GenerateMigration(oldAst, newAst)
+			if err != nil {
+				t.Fatalf("up: %v", err)
+			}
+			if up != test.up {
+				t.Errorf("up migration:\n%s\nwant:\n%s", up, test.up)
+			}
+
+			down, err := GenerateMigration(newAst, oldAst)
+			if err != nil {
+				t.Fatalf("down: %v", err)
+			}
+			if down != test.down {
+				t.Errorf("down migration:\n%s\nwant:\n%s", down, test.down)
+			}
+		})
+	}
+}
+
+func TestGenerateMigrationRenamesPostgresqlConstraints(t *testing.T) {
+	oldAst := parseSchema(t, "postgresql", `
+table people
+	id int @id
+	mail string @unique
+end
+`)
+	newAst := parseSchema(t, "postgresql", `
+table users @renamedFrom("people")
+	id int @id
+	email string @unique @renamedFrom("mail")
+end
+`)
+
+	down, err := GenerateMigration(newAst, oldAst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(down, "RENAME CONSTRAINT users_email_key TO people_mail_key;") {
+		t.Errorf("down migration does not restore the unique constraint name:\n%s", down)
+	}
+}
+
+func TestGenerateMigrationUnchanged(t *testing.T) {
+	schema := parseSchema(t, "sqlite", migrationBase)
+
+	up, err := GenerateMigration(schema, parseSchema(t, "sqlite", migrationBase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(up) != 0 {
+		t.Errorf("expected no migration, got:\n%s", up)
+	}
+}
+
+func TestGenerateMigrationProviderChange(t *testing.T) {
+	_, err := GenerateMigration(parseSchema(t, "sqlite", migrationBase), parseSchema(t, "mysql", migrationBase))
+	if err == nil {
+		t.Fatal("expected an error when the provider changes")
+	}
+}
+
+// applies migrations to a sqlite database, rolls the last one back with its
+// down migration and checks the history refuses edited migrations
+func TestApplyMigrations(t *testing.T) {
+	dir := t.TempDir()
+	migrations := filepath.Join(dir, "migrations")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	_, err := CreateMigration(migrations, "init", parseSchema(t, "sqlite", migrationBase), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := CreateMigration(migrations, "rename", parseSchema(t, "sqlite", `
+table users
+	id int @id
+	full_name string @renamedFrom("name")
+end
+`), now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openDatabase(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	found, err := FindMigrations([]string{migrations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := ApplyMigrations(db, found[:1])
+	if err != nil || len(done) != 1 {
+		t.Fatalf("applied %d migrations: %v", len(done), err)
+	}
+
+	_, err = db.Exec("INSERT INTO users (id, name) VALUES (1, 'Ann')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err = ApplyMigrations(db, found)
+	if err != nil || len(done) != 1 || done[0].Name != filepath.Base(renamed) {
+		t.Fatalf("applied %v: %v", done, err)
+	}
+
+	name := ""
+	err = db.QueryRow("SELECT full_name FROM users WHERE id = 1").Scan(&name)
+	if err != nil || name != "Ann" {
+		t.Fatalf("renamed colmun holds %q: %v", name, err)
+	}
+
+	down, err := os.ReadFile(filepath.Join(renamed, migrationDown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = applyStatements(db, splitStatements(string(down)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow("SELECT name FROM users WHERE id = 1").Scan(&name)
+	if err != nil || name != "Ann" {
+		t.Fatalf("down migration lost the row, got %q: %v", name, err)
+	}
+
+	found[0].SQL += "\n-- edited\n"
+	_, err = ApplyMigrations(db, found)
+	if err == nil || !strings.Contains(err.Error(), "was edited") {
+		t.Fatalf("expected the edited migration to be refused, got %v", err)
+	}
+}
+
+// migrations created within the same second are still applied in the order
+// they were created
+func TestCreateMigrationSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	names := []string{}
+	for i, name := range []string{"zebra", "apple", "mango"} {
+		schema := parseSchema(t, "sqlite", migrationBase+"\ntable "+name+"\n\tid int @id\nend\n")
+		path, err := CreateMigration(dir, name, schema, now.Add(time.Duration(i)*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.Base(path))
+	}
+
+	want := []string{"20240102030405_zebra", "20240102030406_apple", "20240102030407_mango"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names %q, want %q", names, want)
+	}
+}
+
+func TestSnapshotWithoutPositions(t *testing.T) {
+	snapshot, err := snapshotJSON(parseSchema(t, "sqlite", migrationBase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(snapshot, `"pos"`) || strings.Contains(snapshot, `"files"`) {
+		t.Errorf("snapshot has source positions:\n%s", snapshot)
+	}
+
+	parsed, err := ReadASTJSON([]byte(snapshot), "schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Tables) != 1 || len(parsed.Tables[0].Colmuns) != 2 {
+		t.Errorf("snapshot does not read back, got %d tables", len(parsed.Tables))
+	}
+}
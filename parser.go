@@ -2,88 +2,307 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type AST struct {
-	Configuration map[string]string
-	Tables        []*TabelAST
+	Configuration map[string]string `json:"configuration"`
+	Tables        []*TabelAST       `json:"tables"`
+	Files         []string          `json:"files"`
+	Settings      []*SettingAST     `json:"settings"`
+	Imports       []*ImportAST      `json:"imports"`
+}
+
+// a set statement as written, Configuration holds the merged values
+type SettingAST struct {
+	Name  string   `json:"name"`
+	Value string   `json:"value"`
+	Pos   Position `json:"pos"`
+}
+
+type ImportAST struct {
+	Path string   `json:"path"`
+	Pos  Position `json:"pos"`
 }
 
 type TabelAST struct {
-	Name       string
-	Colmuns    []*ColmunAST
-	References []*ReferenceAST
+	Name       string          `json:"name"`
+	Colmuns    []*ColmunAST    `json:"columns"`
+	References []*ReferenceAST `json:"references"`
+	Attributes *AttributesAST  `json:"attributes"`
+	Pos        Position        `json:"pos"`
+	Doc        string          `json:"doc"`
 }
 
 type ColmunAST struct {
-	Name       string
-	Data_type  string
-	Attributes *AttributesAST
+	Name        string         `json:"name"`
+	Data_type   string         `json:"type"`
+	Type_params []string       `json:"typeParams"`
+	Attributes  *AttributesAST `json:"attributes"`
+	Pos         Position       `json:"pos"`
+	Doc         string         `json:"doc"`
 }
 
 type ReferenceAST struct {
-	TargetTable string
-	TargetCol   string
-	SourceCol   string
-	OnDelete    string
-	OnUpdate    string
+	TargetTable string `json:"targetTable"`
+	TargetCol   string `json:"targetColumn"`
+	SourceCol   string `json:"sourceColumn"`
+	OnDelete    string `json:"onDelete"`
+	OnUpdate    string `json:"onUpdate"`
 }
 
 type AttributesAST map[string]*AttributeAST
 
 type AttributeAST struct {
-	Name   string
-	Values []*AttributeArgAST
+	Name   string             `json:"name"`
+	Values []*AttributeArgAST `json:"args"`
+	Pos    Position           `json:"pos"`
 }
 
 type AttributeArgAST struct {
-	Value string
-	Type  string
+	Value string   `json:"value"`
+	Type  string   `json:"type"`
+	Pos   Position `json:"pos"`
+}
+
+// where a node starts in the source
+type Position struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+}
+
+func (p Position) String() string {
+	if len(p.File) == 0 {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+func tokenPos(tok *Token) Position {
+	return Position{tok.File, tok.Line, tok.Col}
 }
 
 var tokenizer *Tokenizer
 var parseAttrFuncMap map[string]func(*Token, []*AttributeArgAST, *ColmunAST) error
-var configurable []string
+var configurable = []string{"provider", "url"}
 var ast *AST
 var currentTableAst *TabelAST
 
-func Parse(localTokenizer *Tokenizer) (*AST, error) {
-	//init
-	tokenizer = localTokenizer
+var parsedFiles map[string]bool
+
+// a @reference with the position of the attribute, for the errors of
+// resolveReferences
+type pendingReference struct {
+	Ref   *ReferenceAST
+	Table string
+	Pos   Position
+	End   Position
+}
+
+var pendingReferences []*pendingReference
+
+func initParseAttrFuncs() {
 	parseAttrFuncMap = map[string]func(*Token, []*AttributeArgAST, *ColmunAST) error{
 		"id":             parseIdAttr,
 		"default":        parseDefaultAttr,
 		"auto_increment": parseAutoIncrementAttr,
 		"nullable":       parseNullableAttr,
+		"unique":         parseUniqueAttr,
 		"reference":      parseReferenceAttr,
 		"onDelete":       parseOnDeleteAttr,
 		"onUpdate":       parseOnUpdateAttr,
+		"renamedFrom":    parseRenamedFromAttr,
+	}
+}
+
+func initParser() {
+	initParseAttrFuncs()
+
+	ast = &AST{
+		map[string]string{"provider": "sqlite"},
+		[]*TabelAST{},
+		[]string{},
+		[]*SettingAST{},
+		[]*ImportAST{},
+	}
+	parsedFiles = map[string]bool{}
+	pendingReferences = []*pendingReference{}
+}
+
+func Parse(localTokenizer *Tokenizer) (*AST, error) {
+	initParser()
+
+	// so an import cycle back to this file does not parse it again
+	if len(localTokenizer.File()) > 0 {
+		absPath, err := filepath.Abs(localTokenizer.File())
+		if err == nil {
+			parsedFiles[absPath] = true
+		}
+	}
+
+	err := parseFile(localTokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resolveReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	return ast, nil
+}
+
+// ParseFiles parses several schema files into one ast, as if they were
+// imported one after the other
+func ParseFiles(paths []string) (*AST, error) {
+	initParser()
+
+	for _, path := range paths {
+		err := parseImportedFile(path, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := resolveReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	return ast, nil
+}
+
+// ExpandInputs turns a schema path into the list of files to parse, a
+// directory gives all its .sqmi files and a glob pattern its matches
+func ExpandInputs(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		path = filepath.Join(path, "*.sqmi")
+	} else if err == nil || !strings.ContainsAny(path, "*?[") {
+		return []string{path}, nil
 	}
 
-	configurable = []string{"provider", "url"}
+	files, err := filepath.Glob(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error: Bad pattern '%s': %v", path, err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("Error: No schema files found for '%s'", path)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func parseFile(localTokenizer *Tokenizer) error {
+	previousTokenizer := tokenizer
+	tokenizer = localTokenizer
+	defer func() { tokenizer = previousTokenizer }()
+
+	if len(tokenizer.File()) > 0 {
+		ast.Files = append(ast.Files, tokenizer.File())
+	}
 
 	tok := tokenizer.NextToken()
-	ast = &AST{map[string]string{"provider": "sqlite"}, []*TabelAST{}}
+	docs := []string{}
 
 	for tok.TokenType != T_EOF {
+		if tok.TokenType == T_DOC {
+			docs = append(docs, strings.TrimPrefix(tok.Literal, " "))
+			tok = tokenizer.NextToken()
+			continue
+		}
+
+		if len(docs) > 0 && tok.TokenType != T_TABLE && tok.TokenType != T_EOL {
+			return createError("Doc comment must come before a table or colmun", tok.Line, tok.Col)
+		}
+
 		if tok.TokenType == T_TABLE {
 			tableDefAst, err := parseTable()
 			if err != nil {
-				return nil, err
+				return err
 			}
+			tableDefAst.Doc = strings.Join(docs, "\n")
+			docs = []string{}
 			ast.Tables = append(ast.Tables, tableDefAst)
 		} else if tok.TokenType == T_SET {
 			err := parseSet()
 			if err != nil {
-				return nil, err
+				return err
+			}
+		} else if tok.TokenType == T_IMPORT {
+			err := parseImport()
+			if err != nil {
+				return err
 			}
 		} else if tok.TokenType != T_EOL {
-			return nil, createError(fmt.Sprintf("Unexpected token '%s'", tok.Literal), tok.Line, tok.Col)
+			return createError(fmt.Sprintf("Unexpected token '%s'", tok.Literal), tok.Line, tok.Col)
 		}
 		tok = tokenizer.NextToken()
 	}
 
-	return ast, nil
+	return nil
+}
+
+func parseImport() error {
+	// import "<path>"
+	tok := tokenizer.NextToken()
+	if tok.TokenType != T_STRING {
+		return createError("Expected file name after 'import'", tok.Line, tok.Col)
+	}
+
+	// paths are relative to the importing file
+	path := tok.Literal
+	if !filepath.IsAbs(path) && len(tokenizer.File()) > 0 {
+		path = filepath.Join(filepath.Dir(tokenizer.File()), path)
+	}
+
+	ast.Imports = append(ast.Imports, &ImportAST{tok.Literal, tokenPos(tok)})
+
+	err := parseImportedFile(path, tok)
+	if err != nil {
+		return err
+	}
+
+	tok = tokenizer.NextToken()
+	if tok.TokenType != T_EOL && tok.TokenType != T_EOF {
+		return createError("Expected end of line", tok.Line, tok.Col)
+	}
+
+	return nil
+}
+
+// parseImportedFile parses a file into the current ast, files that were
+// already parsed are skipped so a file can be imported from several places
+func parseImportedFile(path string, importTok *Token) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	if parsedFiles[absPath] {
+		return nil
+	}
+	parsedFiles[absPath] = true
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if importTok != nil {
+			return createError(fmt.Sprintf("Can not import '%s': %v", importTok.Literal, err), importTok.Line, importTok.Col)
+		}
+		return fmt.Errorf("Error reading file: %s", err)
+	}
+
+	previousTable := currentTableAst
+	defer func() { currentTableAst = previousTable }()
+
+	return parseFile(NewFileTokenizer(path, string(content)))
 }
 
 func parseSet() error {
@@ -95,6 +314,7 @@ func parseSet() error {
 		}
 
 		configurable := tok.Literal
+		pos := tokenPos(tok)
 
 		tok = tokenizer.NextToken()
 
@@ -113,6 +333,7 @@ func parseSet() error {
 		}
 
 		ast.Configuration[configurable] = tok.Literal
+		ast.Settings = append(ast.Settings, &SettingAST{configurable, tok.Literal, pos})
 		tokenizer.NextToken()
 	} else {
 		return createError(fmt.Sprintf("Expected identifier after 'set'"), tok.Line, tok.Col)
@@ -149,16 +370,37 @@ func parseTable() (*TabelAST, error) {
 		}
 	}
 
-	tableAst := &TabelAST{tok.Literal, []*ColmunAST{}, []*ReferenceAST{}}
+	tableAst := &TabelAST{tok.Literal, []*ColmunAST{}, []*ReferenceAST{}, &AttributesAST{}, tokenPos(tok), ""}
 	currentTableAst = tableAst
 
-	exists, _ := getTableByName(tok.Literal)
+	exists, declared := getTableByName(tok.Literal)
 	if exists {
 		return nil, createError(
-			fmt.Sprintf("Table with name '%s' already declared", tok.Literal), tok.Line, tok.Col)
+			fmt.Sprintf("Table with name '%s' already declared at %s", tok.Literal, declared.Pos),
+			tok.Line,
+			tok.Col,
+		)
 	}
 
+	// table <TableName> [@renamedFrom("<old name>")]
 	tok = tokenizer.NextToken()
+	for tok.TokenType == T_ATTR {
+		next, args, err := parseColAttr(tok)
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Literal != "renamedFrom" {
+			return nil, createError(fmt.Sprintf("Unknown table attribute @%s", tok.Literal), tok.Line, tok.Col)
+		}
+		if len(args) != 1 || args[0].Type != "string" {
+			return nil, createError("@renamedFrom takes one string parameter", tok.Line, tok.Col)
+		}
+		(*tableAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
+
+		tok = next
+	}
+
 	if tok.TokenType != T_EOL {
 		return nil, createError("Expected end of line", tok.Line, tok.Col)
 	}
@@ -181,17 +423,28 @@ func parseCols() error {
 	// <colmunName> <fieldType> [<@attribute>]
 	tok := tokenizer.NextToken()
 
-	if tok.TokenType != T_IDEN {
+	if tok.TokenType != T_IDEN && tok.TokenType != T_DOC {
 		return createError(fmt.Sprintf("Unexpected token '%s'", tok.Literal), tok.Line, tok.Col)
 	}
 
+	docs := []string{}
+
 	for tok.TokenType != T_END && tok.TokenType != T_EOF {
 
 		if tok.TokenType == T_TABLE {
 			return createError("Missing 'end' keyword", tok.Line, tok.Col)
 		}
 
-		colAst := &ColmunAST{tok.Literal, "", &AttributesAST{}}
+		// doc comments are on their own line before the colmun
+		if tok.TokenType == T_DOC {
+			docs = append(docs, strings.TrimPrefix(tok.Literal, " "))
+			tokenizer.NextToken()
+			tok = tokenizer.NextToken()
+			continue
+		}
+
+		colAst := &ColmunAST{tok.Literal, "", []string{}, &AttributesAST{}, tokenPos(tok), strings.Join(docs, "\n")}
+		docs = []string{}
 
 		if tok.TokenType != T_IDEN {
 			return createError(fmt.Sprintf("Unexpected token '%s'", tok.Literal), tok.Line, tok.Col)
@@ -209,7 +462,7 @@ func parseCols() error {
 
 		err := parseColType(colAst)
 		if err != nil {
-			return nil
+			return err
 		}
 
 		err = ParseColAttributes(colAst)
@@ -221,6 +474,10 @@ func parseCols() error {
 		tok = tokenizer.NextToken()
 	}
 
+	if len(docs) > 0 {
+		return createError("Doc comment must come before a table or colmun", tok.Line, tok.Col)
+	}
+
 	return nil
 }
 
@@ -238,9 +495,11 @@ func parseColType(colAst *ColmunAST) error {
 	if tok.TokenType != T_IDEN {
 		if tok.TokenType == T_RAW {
 			colAst.Data_type = "raw"
+			pos := tokenPos(tok)
 			(*colAst.Attributes)["raw"] = &AttributeAST{
 				"raw",
-				[]*AttributeArgAST{{tok.Literal, "string"}},
+				[]*AttributeArgAST{{tok.Literal, "string", pos}},
+				pos,
 			}
 			tokenizer.NextToken()
 		} else {
@@ -249,10 +508,45 @@ func parseColType(colAst *ColmunAST) error {
 	} else {
 		tokenizer.NextToken()
 		colAst.Data_type = tok.Literal
+
+		if tokenizer.PeekToken().TokenType == T_LEFT_PAREN {
+			tokenizer.NextToken()
+			params, err := parseTypeParams()
+			if err != nil {
+				return err
+			}
+			colAst.Type_params = params
+		}
 	}
 	return nil
 }
 
+func parseTypeParams() ([]string, error) {
+	// (<number> [, <number>])
+	params := []string{}
+
+	for {
+		tok := tokenizer.NextToken()
+		if tok.TokenType != T_NUM {
+			return nil, createError(
+				fmt.Sprintf("Expected number in type parameters, got '%s'", tok.Literal),
+				tok.Line,
+				tok.Col,
+			)
+		}
+		params = append(params, tok.Literal)
+
+		tok = tokenizer.NextToken()
+		if tok.TokenType == T_RIGHT_PAREN {
+			break
+		} else if tok.TokenType != T_COMMA {
+			return nil, createError(fmt.Sprintf("Unexpected token '%s'", tok.Literal), tok.Line, tok.Col)
+		}
+	}
+
+	return params, nil
+}
+
 func ParseColAttributes(colAst *ColmunAST) error {
 	tok := tokenizer.NextToken()
 
@@ -318,6 +612,7 @@ func getAttrArgs(tok *Token) ([]*AttributeArgAST, error) {
 		}
 
 		attrArg.Value = tok.Literal
+		attrArg.Pos = tokenPos(tok)
 		attrArgs = append(attrArgs, attrArg)
 
 		tok = tokenizer.NextToken()
@@ -334,6 +629,10 @@ func getAttrArgs(tok *Token) ([]*AttributeArgAST, error) {
 }
 
 func parseAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) error {
+	if strings.HasPrefix(tok.Literal, "db.") {
+		return parseDbAttr(tok, args, colAst)
+	}
+
 	f, exists := parseAttrFuncMap[tok.Literal]
 	if !exists {
 		return createError(fmt.Sprintf("Unknown attribute @%s", tok.Literal), tok.Line, tok.Col)
@@ -346,7 +645,7 @@ func parseIdAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) error {
 	if len(args) != 0 {
 		return createError("@id takes no parameters", tok.Line, tok.Col)
 	}
-	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args}
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
 	return nil
 }
 
@@ -354,7 +653,7 @@ func parseDefaultAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) er
 	if len(args) != 1 {
 		return createError("@default takes one parameters", tok.Line, tok.Col)
 	}
-	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args}
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
 	return nil
 }
 
@@ -362,7 +661,7 @@ func parseAutoIncrementAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunA
 	if len(args) != 0 {
 		return createError("@auto_increment takes no parameters", tok.Line, tok.Col)
 	}
-	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args}
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
 	return nil
 }
 
@@ -370,7 +669,7 @@ func parseUniqueAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) err
 	if len(args) != 0 {
 		return createError("@unique takes no parameters", tok.Line, tok.Col)
 	}
-	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args}
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
 	return nil
 }
 
@@ -378,7 +677,36 @@ func parseNullableAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) e
 	if len(args) != 0 {
 		return createError("@nullable takes no parameters", tok.Line, tok.Col)
 	}
-	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args}
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
+	return nil
+}
+
+// @renamedFrom("<old name>") tells migrations the colmun was renamed, not
+// dropped and added
+func parseRenamedFromAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) error {
+	if len(args) != 1 || args[0].Type != "string" {
+		return createError("@renamedFrom takes one string parameter", tok.Line, tok.Col)
+	}
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
+	return nil
+}
+
+// @db.<provider>(`TYPE`) overrides the colmun type for a single provider
+func parseDbAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) error {
+	providerName := strings.TrimPrefix(tok.Literal, "db.")
+	if !isProviderAvailable(providerName) {
+		return createError(fmt.Sprintf("Unknown provider '%s' in @%s", providerName, tok.Literal), tok.Line, tok.Col)
+	}
+
+	if len(args) != 1 {
+		return createError(fmt.Sprintf("@%s takes one parameters", tok.Literal), tok.Line, tok.Col)
+	}
+
+	if _, exists := (*colAst.Attributes)[tok.Literal]; exists {
+		return createError(fmt.Sprintf("@%s already declared", tok.Literal), tok.Line, tok.Col)
+	}
+
+	(*colAst.Attributes)[tok.Literal] = &AttributeAST{tok.Literal, args, tokenPos(tok)}
 	return nil
 }
 
@@ -391,30 +719,44 @@ func parseReferenceAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST)
 		return createError("@reference Expected string values", tok.Line, tok.Col)
 	}
 
-	exists, table := getTableByName(args[0].Value)
-	if !exists {
-		return createError(
-			fmt.Sprintf("no such table '%s'", args[0].Value),
-			tok.Line,
-			tok.Col,
-		)
-	}
+	ref := &ReferenceAST{args[0].Value, args[1].Value, colAst.Name, "", ""}
+	currentTableAst.References = append(currentTableAst.References, ref)
 
-	if !checkIfColExists(args[1].Value, table) {
-		return createError(
-			fmt.Sprintf("no such col '%s' on table '%s'", args[1].Value, table.Name),
-			tok.Line,
-			tok.Col,
-		)
-	}
+	// the target may be declared later or in another file, it is checked by
+	// resolveReferences once everything is parsed
+	pos := tokenPos(tok)
+	end := Position{pos.File, tok.Line, tokenizer.EndOf(tok.Line, tok.Col)}
+	pendingReferences = append(pendingReferences, &pendingReference{ref, currentTableAst.Name, pos, end})
+	return nil
+}
 
-	currentTableAst.References = append(
-		currentTableAst.References,
-		&ReferenceAST{args[0].Value, args[1].Value, colAst.Name, "", ""},
-	)
+// resolveReferences checks that every @reference targets a declared table and
+// colmun
+func resolveReferences() error {
+	for _, pending := range pendingReferences {
+		exists, table := getTableByName(pending.Ref.TargetTable)
+		if !exists {
+			return pending.errorf("no such table '%s'", pending.Ref.TargetTable)
+		}
+
+		if !checkIfColExists(pending.Ref.TargetCol, table) {
+			return pending.errorf("no such col '%s' on table '%s'", pending.Ref.TargetCol, table.Name)
+		}
+	}
 	return nil
 }
 
+func (p *pendingReference) errorf(format string, args ...interface{}) error {
+	return &Diagnostic{
+		Kind:     "Syntax",
+		Severity: severityError,
+		Code:     "syntax-error",
+		Pos:      p.Pos,
+		End:      p.End,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
 func parseOnDeleteAttr(tok *Token, args []*AttributeArgAST, colAst *ColmunAST) error {
 	if len(args) != 1 {
 		return createError("@onDelete takes one parameters", tok.Line, tok.Col)
@@ -473,5 +815,19 @@ func getRefByColName(colName string) (*ReferenceAST, bool) {
 }
 
 func createError(msg string, line int, col int) error {
-	return fmt.Errorf("Syntax Error:%d:%d: %s", line, col, msg)
+	pos := Position{"", line, col}
+	end := pos
+	if tokenizer != nil {
+		pos.File = tokenizer.File()
+		end = Position{pos.File, line, tokenizer.EndOf(line, col)}
+	}
+
+	return &Diagnostic{
+		Kind:     "Syntax",
+		Severity: severityError,
+		Code:     "syntax-error",
+		Pos:      pos,
+		End:      end,
+		Message:  msg,
+	}
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSchemas writes the files into a new directory and gives its path
+func writeSchemas(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		writeSchema(t, dir, name, content)
+	}
+	return dir
+}
+
+func tableNames(ast *AST) []string {
+	names := []string{}
+	for _, table := range ast.Tables {
+		names = append(names, table.Name)
+	}
+	return names
+}
+
+func TestParseFiles(t *testing.T) {
+	users := "table users\n\tid int @id\nend\n"
+	posts := "table posts\n\tid int @id\n\tauthor int @reference(\"users\", \"id\")\nend\n"
+
+	tests := []struct {
+		name   string
+		files  map[string]string
+		inputs []string
+		tables []string
+	}{
+		{
+			"import",
+			map[string]string{"main.sqmi": "import \"users.sqmi\"\n\n" + posts, "users.sqmi": users},
+			[]string{"main.sqmi"},
+			[]string{"users", "posts"},
+		},
+		{
+			"import relative to the importing file",
+			map[string]string{
+				"main.sqmi":         "import \"models/posts.sqmi\"\n",
+				"models/posts.sqmi": "import \"users.sqmi\"\n" + posts,
+				"models/users.sqmi": users,
+			},
+			[]string{"main.sqmi"},
+			[]string{"users", "posts"},
+		},
+		{
+			"file imported twice",
+			map[string]string{
+				"main.sqmi":  "import \"users.sqmi\"\nimport \"posts.sqmi\"\n",
+				"posts.sqmi": "import \"users.sqmi\"\n" + posts,
+				"users.sqmi": users,
+			},
+			[]string{"main.sqmi"},
+			[]string{"users", "posts"},
+		},
+		{
+			"import cycle",
+			map[string]string{"posts.sqmi": "import \"users.sqmi\"\n" + posts, "users.sqmi": "import \"posts.sqmi\"\n" + users},
+			[]string{"posts.sqmi"},
+			[]string{"users", "posts"},
+		},
+		{
+			"reference to a later file",
+			map[string]string{"a_posts.sqmi": posts, "b_users.sqmi": users},
+			[]string{"."},
+			[]string{"posts", "users"},
+		},
+		{
+			"glob pattern",
+			map[string]string{"posts.sqmi": posts, "users.sqmi": users, "notes.txt": "not a schema"},
+			[]string{"*.sqmi"},
+			[]string{"posts", "users"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeSchemas(t, test.files)
+
+			paths := []string{}
+			for _, input := range test.inputs {
+				expanded, err := ExpandInputs(filepath.Join(dir, input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, expanded...)
+			}
+
+			parsed, err := ParseFiles(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := tableNames(parsed); !reflect.DeepEqual(names, test.tables) {
+				t.Errorf("tables %q, want %q", names, test.tables)
+			}
+		})
+	}
+}
+
+func TestParseFilesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		error string
+	}{
+		{
+			"table declared in two files",
+			map[string]string{"main.sqmi": "import \"users.sqmi\"\ntable users\n\tid int\nend\n", "users.sqmi": "table users\n\tid int\nend\n"},
+			"Table with name 'users' already declared at ",
+		},
+		{
+			"missing import",
+			map[string]string{"main.sqmi": "import \"users.sqmi\"\n"},
+			"Can not import 'users.sqmi'",
+		},
+		{
+			"reference to an unknown table",
+			map[string]string{"main.sqmi": "table posts\n\tauthor int @reference(\"users\", \"id\")\nend\n"},
+			"no such table 'users'",
+		},
+		{
+			"reference to an unknown colmun",
+			map[string]string{"main.sqmi": "import \"users.sqmi\"\ntable posts\n\tauthor int @reference(\"users\", \"uid\")\nend\n", "users.sqmi": "table users\n\tid int\nend\n"},
+			"no such col 'uid' on table 'users'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeSchemas(t, test.files)
+
+			_, err := ParseFiles([]string{filepath.Join(dir, "main.sqmi")})
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+func TestExpandInputsNoMatch(t *testing.T) {
+	_, err := ExpandInputs(filepath.Join(t.TempDir(), "*.sqmi"))
+	if err == nil || !strings.Contains(err.Error(), "No schema files found") {
+		t.Errorf("error %v, want no schema files found", err)
+	}
+}
+
+// references are resolved once every file is parsed, a table may reference
+// itself and tables may reference each other
+func TestParseReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		sorted []string
+	}{
+		{
+			"reference to a later table",
+			"table posts\n\tauthor int @reference(\"users\", \"id\")\nend\ntable users\n\tid int @id\nend\n",
+			[]string{"users", "posts"},
+		},
+		{
+			"reference to the own table",
+			"table employees\n\tid int @id\n\tmanager int @nullable @reference(\"employees\", \"id\")\nend\n",
+			[]string{"employees"},
+		},
+		{
+			"tables referencing each other",
+			"table a\n\tid int @id\n\tb int @reference(\"b\", \"id\")\nend\ntable b\n\tid int @id\n\ta int @reference(\"a\", \"id\")\nend\n",
+			[]string{"b", "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed := parseSchema(t, "sqlite", test.schema)
+
+			sql, err := GenerateSQL(parsed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sorted := []string{}
+			for _, line := range strings.Split(sql, "\n") {
+				if strings.HasPrefix(line, "CREATE TABLE ") {
+					sorted = append(sorted, strings.Fields(line)[2])
+				}
+			}
+			if !reflect.DeepEqual(sorted, test.sorted) {
+				t.Errorf("tables created in the order %q, want %q", sorted, test.sorted)
+			}
+		})
+	}
+}
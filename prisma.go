@@ -0,0 +1,604 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// prisma scalar types and the logical type they become
+var prismaTypes = map[string]string{
+	"String":   "string",
+	"Int":      "int",
+	"BigInt":   "bigint",
+	"Boolean":  "bool",
+	"DateTime": "datetime",
+	"Float":    "float",
+	"Decimal":  "decimal",
+	"Json":     "json",
+	"Bytes":    "bytes",
+}
+
+// native types from @db that have a logical type, the others are kept as a
+// @db.<provider> override
+var prismaNativeTypes = map[string]string{
+	"VarChar":     "string",
+	"Char":        "char",
+	"Text":        "text",
+	"Uuid":        "uuid",
+	"Timestamp":   "datetime",
+	"Timestamptz": "timestamptz",
+	"Date":        "date",
+	"Time":        "time",
+	"SmallInt":    "smallint",
+	"Decimal":     "decimal",
+}
+
+var prismaActions = map[string]string{
+	"Cascade":    "CASCADE",
+	"Restrict":   "RESTRICT",
+	"NoAction":   "NO ACTION",
+	"SetNull":    "SET NULL",
+	"SetDefault": "SET DEFAULT",
+}
+
+var (
+	prismaBlockRegex = regexp.MustCompile(`^(model|enum|datasource|generator|type|view)\s+(\w+)\s*\{$`)
+	prismaFieldRegex = regexp.MustCompile(`^(\w+)\s+(\w+|Unsupported\("[^"]*"\))(\[\])?(\?)?(\s+.*)?$`)
+	prismaAttrRegex  = regexp.MustCompile(`^@@?[\w.]+`)
+	prismaNamedArg   = regexp.MustCompile(`^(\w+)\s*:\s*(.*)$`)
+)
+
+type prismaModel struct {
+	Name   string
+	Doc    string
+	Line   int
+	Fields []*prismaField
+	Attrs  []*prismaAttr
+}
+
+type prismaField struct {
+	Name     string
+	Type     string
+	List     bool
+	Optional bool
+	Doc      string
+	Line     int
+	Attrs    []*prismaAttr
+}
+
+type prismaAttr struct {
+	Name string
+	Args []*prismaArg
+}
+
+// Name is empty for positional arguments
+type prismaArg struct {
+	Name  string
+	Value string
+}
+
+type prismaImporter struct {
+	models   []*prismaModel
+	enums    map[string]bool
+	provider string
+	warnings []string
+}
+
+// ImportPrisma converts the models of a prisma schema, relation fields become
+// references on the colmuns listed in @relation(fields: ...)
+func ImportPrisma(content string) (*AST, []string, error) {
+	initValues()
+
+	p := &prismaImporter{[]*prismaModel{}, map[string]bool{}, "", []string{}}
+	err := p.parse(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ast := &AST{
+		map[string]string{"provider": "sqlite"},
+		[]*TabelAST{},
+		[]string{},
+		[]*SettingAST{},
+		[]*ImportAST{},
+	}
+
+	if len(p.provider) > 0 {
+		if isProviderAvailable(p.provider) {
+			ast.Configuration["provider"] = p.provider
+			ast.Settings = append(ast.Settings, &SettingAST{"provider", p.provider, Position{}})
+		} else {
+			p.warnf("Provider '%s' is not supported, using sqlite", p.provider)
+		}
+	}
+
+	for _, model := range p.models {
+		table, err := p.convertModel(model)
+		if err != nil {
+			return nil, nil, err
+		}
+		ast.Tables = append(ast.Tables, table)
+	}
+
+	// every table has to exist before relations can point at them
+	for _, model := range p.models {
+		err := p.convertRelations(ast, model)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sorted, warnings := sortTablesByReferences(ast)
+	ast.Tables = sorted
+	return ast, append(p.warnings, warnings...), nil
+}
+
+func (p *prismaImporter) parse(content string) error {
+	var model *prismaModel
+	block := ""
+	docs := []string{}
+
+	for i, rawLine := range strings.Split(content, "\n") {
+		lineNumber := i + 1
+		trimmed := strings.TrimSpace(rawLine)
+
+		if strings.HasPrefix(trimmed, "///") {
+			docs = append(docs, strings.TrimPrefix(strings.TrimPrefix(trimmed, "///"), " "))
+			continue
+		}
+
+		line := strings.TrimSpace(stripPrismaComment(trimmed))
+		if len(line) == 0 {
+			continue
+		}
+		doc := strings.Join(docs, "\n")
+		docs = []string{}
+
+		if len(block) == 0 {
+			match := prismaBlockRegex.FindStringSubmatch(line)
+			if match == nil {
+				return fmt.Errorf("Error: line %d: Expected a model, enum, datasource or generator", lineNumber)
+			}
+
+			block = match[1]
+			switch block {
+			case "model":
+				model = &prismaModel{match[2], doc, lineNumber, []*prismaField{}, []*prismaAttr{}}
+				p.models = append(p.models, model)
+			case "enum":
+				p.enums[match[2]] = true
+				p.warnf("line %d: Enum '%s' is not supported, its fields become strings", lineNumber, match[2])
+			case "type", "view":
+				p.warnf("line %d: %s '%s' is not supported, skipped", lineNumber, block, match[2])
+			}
+			continue
+		}
+
+		if line == "}" {
+			block = ""
+			model = nil
+			continue
+		}
+
+		switch block {
+		case "datasource":
+			name, value, found := strings.Cut(line, "=")
+			if found && strings.TrimSpace(name) == "provider" {
+				p.provider, _ = strconv.Unquote(strings.TrimSpace(value))
+			}
+		case "model":
+			if strings.HasPrefix(line, "@@") {
+				attrs, err := parsePrismaAttributes(line, lineNumber)
+				if err != nil {
+					return err
+				}
+				model.Attrs = append(model.Attrs, attrs...)
+				continue
+			}
+
+			match := prismaFieldRegex.FindStringSubmatch(line)
+			if match == nil {
+				return fmt.Errorf("Error: line %d: Invalid field '%s'", lineNumber, line)
+			}
+
+			attrs, err := parsePrismaAttributes(strings.TrimSpace(match[5]), lineNumber)
+			if err != nil {
+				return err
+			}
+
+			model.Fields = append(model.Fields, &prismaField{
+				match[1],
+				match[2],
+				len(match[3]) > 0,
+				len(match[4]) > 0,
+				doc,
+				lineNumber,
+				attrs,
+			})
+		}
+	}
+
+	if len(block) > 0 {
+		return fmt.Errorf("Error: Missing '}' at the end of the file")
+	}
+	return nil
+}
+
+func (p *prismaImporter) convertModel(model *prismaModel) (*TabelAST, error) {
+	name := prismaMappedName(model.Attrs, "@map", model.Name)
+	if !isValidTableName(name) {
+		return nil, fmt.Errorf("Error: line %d: Invalid table name '%s'", model.Line, name)
+	}
+
+	table := &TabelAST{name, []*ColmunAST{}, []*ReferenceAST{}, &AttributesAST{}, Position{}, model.Doc}
+
+	for _, attr := range model.Attrs {
+		switch attr.Name {
+		case "@map":
+		case "@id", "@unique":
+			// on a single field they are the same as the field attribute
+			fields := prismaList(attr.Args[0].Value)
+			if len(fields) == 1 {
+				field := p.findField(model, fields[0])
+				if field != nil {
+					field.Attrs = append(field.Attrs, &prismaAttr{strings.TrimPrefix(attr.Name, "@"), []*prismaArg{}})
+					continue
+				}
+			}
+			p.warnf("line %d: @%s on several fields of model '%s' is not supported, skipped", model.Line, attr.Name, model.Name)
+		default:
+			p.warnf("line %d: @%s of model '%s' is not supported, skipped", model.Line, attr.Name, model.Name)
+		}
+	}
+
+	for _, field := range model.Fields {
+		if p.isModel(field.Type) {
+			continue
+		}
+		if field.List {
+			p.warnf("line %d: List field '%s' is not supported, skipped", field.Line, field.Name)
+			continue
+		}
+
+		colmun, err := p.convertField(field)
+		if err != nil {
+			return nil, err
+		}
+		table.Colmuns = append(table.Colmuns, colmun)
+	}
+
+	return table, nil
+}
+
+func (p *prismaImporter) convertField(field *prismaField) (*ColmunAST, error) {
+	name := prismaMappedName(field.Attrs, "map", field.Name)
+	if !isValidColmunName(name) {
+		return nil, fmt.Errorf("Error: line %d: Invalid colmun name '%s'", field.Line, name)
+	}
+
+	colmun := &ColmunAST{name, "", []string{}, &AttributesAST{}, Position{}, field.Doc}
+	attrs := *colmun.Attributes
+	setAttr := func(name string, args ...*AttributeArgAST) {
+		if args == nil {
+			args = []*AttributeArgAST{}
+		}
+		attrs[name] = &AttributeAST{name, args, Position{}}
+	}
+
+	switch {
+	case strings.HasPrefix(field.Type, "Unsupported("):
+		raw, _ := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(field.Type, "Unsupported("), ")"))
+		colmun.Data_type = "raw"
+		setAttr("raw", &AttributeArgAST{raw, "string", Position{}})
+	case p.enums[field.Type]:
+		colmun.Data_type = "string"
+	default:
+		logical, exists := prismaTypes[field.Type]
+		if !exists {
+			return nil, fmt.Errorf("Error: line %d: Unknown type '%s' of field '%s'", field.Line, field.Type, field.Name)
+		}
+		colmun.Data_type = logical
+	}
+
+	if field.Optional {
+		setAttr("nullable")
+	}
+
+	for _, attr := range field.Attrs {
+		switch {
+		case attr.Name == "id" || attr.Name == "unique":
+			setAttr(attr.Name)
+		case attr.Name == "map" || attr.Name == "relation" || attr.Name == "updatedAt" || attr.Name == "ignore":
+			if attr.Name == "updatedAt" {
+				p.warnf("line %d: @updatedAt of '%s' is set by Prisma Client, skipped", field.Line, field.Name)
+			}
+		case attr.Name == "default" && len(attr.Args) == 1:
+			p.convertDefault(field, colmun, attr.Args[0].Value)
+		case strings.HasPrefix(attr.Name, "db."):
+			p.convertNativeType(field, colmun, attr)
+		default:
+			p.warnf("line %d: @%s of '%s' is not supported, skipped", field.Line, attr.Name, field.Name)
+		}
+	}
+
+	return colmun, nil
+}
+
+func (p *prismaImporter) convertDefault(field *prismaField, colmun *ColmunAST, value string) {
+	attrs := *colmun.Attributes
+	setDefault := func(value string, argType string) {
+		attrs["default"] = &AttributeAST{"default", []*AttributeArgAST{{value, argType, Position{}}}, Position{}}
+	}
+
+	switch {
+	case value == "autoincrement()":
+		attrs["auto_increment"] = &AttributeAST{"auto_increment", []*AttributeArgAST{}, Position{}}
+	case value == "now()":
+		setDefault("CURRENT_TIMESTAMP", "raw")
+	case strings.HasPrefix(value, "dbgenerated("):
+		expr, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(value, "dbgenerated("), ")"))
+		if err == nil && len(expr) > 0 {
+			setDefault(expr, "raw")
+		}
+	case strings.HasSuffix(value, ")"):
+		p.warnf("line %d: Default %s of '%s' is generated by Prisma Client, skipped", field.Line, value, field.Name)
+	case strings.HasPrefix(value, "\""):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			p.warnf("line %d: Invalid default %s of '%s', skipped", field.Line, value, field.Name)
+			return
+		}
+		setDefault(unquoted, "string")
+	case strings.HasPrefix(value, "["):
+		p.warnf("line %d: List default of '%s' is not supported, skipped", field.Line, field.Name)
+	default:
+		// numbers, booleans and enum values
+		setDefault(value, "string")
+	}
+}
+
+// convertNativeType turns @db.VarChar(255) and the like into a logical type
+// when there is one, and into a @db.<provider> override otherwise
+func (p *prismaImporter) convertNativeType(field *prismaField, colmun *ColmunAST, attr *prismaAttr) {
+	native := strings.TrimPrefix(attr.Name, "db.")
+	params := []string{}
+	for _, arg := range attr.Args {
+		params = append(params, arg.Value)
+	}
+
+	if logical, exists := prismaNativeTypes[native]; exists && colmun.Data_type != "raw" {
+		_, isType := types[logical]
+		paramType, takesParams := paramTypes[logical]
+		if (len(params) == 0 && isType) || (takesParams && len(params) >= paramType.Min && len(params) <= paramType.Max) {
+			colmun.Data_type = logical
+			colmun.Type_params = params
+			return
+		}
+	}
+
+	if !isProviderAvailable(p.provider) {
+		p.warnf("line %d: Native type @%s of '%s' is not supported, skipped", field.Line, attr.Name, field.Name)
+		return
+	}
+
+	sqlType := strings.ToUpper(native)
+	if len(params) > 0 {
+		sqlType += "(" + strings.Join(params, ",") + ")"
+	}
+	name := "db." + p.provider
+	(*colmun.Attributes)[name] = &AttributeAST{name, []*AttributeArgAST{{sqlType, "raw", Position{}}}, Position{}}
+}
+
+func (p *prismaImporter) convertRelations(ast *AST, model *prismaModel) error {
+	table := findTable(ast, prismaMappedName(model.Attrs, "@map", model.Name))
+
+	for _, field := range model.Fields {
+		relation := findPrismaAttr(field.Attrs, "relation")
+		if !p.isModel(field.Type) || relation == nil {
+			continue
+		}
+
+		fields, references := []string{}, []string{}
+		ref := &ReferenceAST{}
+		for _, arg := range relation.Args {
+			switch arg.Name {
+			case "fields":
+				fields = prismaList(arg.Value)
+			case "references":
+				references = prismaList(arg.Value)
+			case "onDelete":
+				ref.OnDelete = prismaActions[arg.Value]
+			case "onUpdate":
+				ref.OnUpdate = prismaActions[arg.Value]
+			}
+		}
+
+		// the other side of a relation has no fields
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 1 || len(references) != 1 {
+			p.warnf("line %d: Relation '%s' on several fields is not supported, skipped", field.Line, field.Name)
+			continue
+		}
+
+		targetModel := p.findModel(field.Type)
+		sourceField := p.findField(model, fields[0])
+		targetField := p.findField(targetModel, references[0])
+		if sourceField == nil || targetField == nil {
+			return fmt.Errorf("Error: line %d: Relation '%s' uses an unknown field", field.Line, field.Name)
+		}
+
+		ref.TargetTable = prismaMappedName(targetModel.Attrs, "@map", targetModel.Name)
+		ref.TargetCol = prismaMappedName(targetField.Attrs, "map", targetField.Name)
+		ref.SourceCol = prismaMappedName(sourceField.Attrs, "map", sourceField.Name)
+		table.References = append(table.References, ref)
+	}
+
+	return nil
+}
+
+func (p *prismaImporter) isModel(name string) bool {
+	return p.findModel(name) != nil
+}
+
+func (p *prismaImporter) findModel(name string) *prismaModel {
+	for _, model := range p.models {
+		if model.Name == name {
+			return model
+		}
+	}
+	return nil
+}
+
+func (p *prismaImporter) findField(model *prismaModel, name string) *prismaField {
+	for _, field := range model.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func (p *prismaImporter) warnf(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+func findPrismaAttr(attrs []*prismaAttr, name string) *prismaAttr {
+	for _, attr := range attrs {
+		if attr.Name == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// prismaMappedName gives the database name set with @map or @@map
+func prismaMappedName(attrs []*prismaAttr, mapAttr string, name string) string {
+	attr := findPrismaAttr(attrs, mapAttr)
+	if attr == nil || len(attr.Args) == 0 {
+		return name
+	}
+
+	mapped, err := strconv.Unquote(attr.Args[0].Value)
+	if err != nil {
+		return name
+	}
+	return mapped
+}
+
+// parsePrismaAttributes reads @name(args) @other, block attributes keep one
+// @ in their name like @map for @@map
+func parsePrismaAttributes(s string, line int) ([]*prismaAttr, error) {
+	attrs := []*prismaAttr{}
+
+	for s = strings.TrimSpace(s); len(s) > 0; s = strings.TrimSpace(s) {
+		name := prismaAttrRegex.FindString(s)
+		if len(name) == 0 {
+			return nil, fmt.Errorf("Error: line %d: Unexpected '%s'", line, s)
+		}
+		s = s[len(name):]
+
+		attr := &prismaAttr{strings.TrimPrefix(name, "@"), []*prismaArg{}}
+		if strings.HasPrefix(s, "(") {
+			end := matchingParen(s)
+			if end < 0 {
+				return nil, fmt.Errorf("Error: line %d: Missing ')' in %s", line, name)
+			}
+
+			for _, arg := range splitTopLevel(s[1:end]) {
+				if match := prismaNamedArg.FindStringSubmatch(arg); match != nil {
+					attr.Args = append(attr.Args, &prismaArg{match[1], strings.TrimSpace(match[2])})
+				} else {
+					attr.Args = append(attr.Args, &prismaArg{"", arg})
+				}
+			}
+			s = s[end+1:]
+		}
+
+		attrs = append(attrs, attr)
+	}
+
+	return attrs, nil
+}
+
+// matchingParen gives the index of the paren closing the one s starts with
+func matchingParen(s string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case inString && ch == '\\':
+			i++
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '(' || ch == '[':
+			depth++
+		case ch == ')' || ch == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits on the commas that are not in a string, a list or a
+// function call
+func splitTopLevel(s string) []string {
+	parts := []string{}
+	depth := 0
+	inString := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case inString && ch == '\\':
+			i++
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '(' || ch == '[':
+			depth++
+		case ch == ')' || ch == ']':
+			depth--
+		case ch == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	if last := strings.TrimSpace(s[start:]); len(last) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// prismaList reads [a, b] as its items, a single name is a list of one
+func prismaList(s string) []string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	return splitTopLevel(s)
+}
+
+// stripPrismaComment removes a // comment that is not inside a string
+func stripPrismaComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inString && line[i] == '\\':
+			i++
+		case line[i] == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(line[i:], "//"):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportPrisma(t *testing.T) {
+	tests := []struct {
+		name     string
+		prisma   string
+		schema   string
+		warnings []string
+	}{
+		{
+			"fields and defaults",
+			`
+datasource db {
+  provider = "postgresql"
+  url      = env("DATABASE_URL")
+}
+
+/// A registered user
+model User {
+  id        Int      @id @default(autoincrement())
+  email     String   @unique
+  name      String?
+  active    Boolean  @default(true)
+  score     Float    @default(1.5)
+  nickname  String   @default("anonymous")
+  createdAt DateTime @default(now())
+  updatedAt DateTime @default(dbgenerated("CURRENT_TIMESTAMP"))
+}
+`,
+			`set provider postgresql
+
+/// A registered user
+table User
+	id        int      @id @auto_increment
+	email     string   @unique
+	name      string   @nullable
+	active    bool     @default("true")
+	score     float    @default("1.5")
+	nickname  string   @default("anonymous")
+	createdAt datetime @default(` + "`CURRENT_TIMESTAMP`" + `)
+	updatedAt datetime @default(` + "`CURRENT_TIMESTAMP`" + `)
+end
+`,
+			[]string{},
+		},
+		{
+			"map and native types",
+			`
+datasource db {
+  provider = "postgresql"
+  url      = env("DATABASE_URL")
+}
+
+model User {
+  id        Int      @id
+  email     String   @db.VarChar(200)
+  createdAt DateTime @map("created_at")
+  data      Json     @db.JsonB
+
+  @@map("users")
+}
+`,
+			`set provider postgresql
+
+table users
+	id         int         @id
+	email      string(200)
+	created_at datetime
+	data       json        @db.postgresql(` + "`JSONB`" + `)
+end
+`,
+			[]string{},
+		},
+		{
+			"relations",
+			`
+model Post {
+  id       Int   @id
+  authorId Int   @map("author_id")
+  author   User  @relation(fields: [authorId], references: [id], onDelete: Cascade, onUpdate: SetNull)
+  editorId Int?
+  editor   User? @relation("edits", fields: [editorId], references: [id])
+
+  @@map("posts")
+}
+
+model User {
+  id     Int    @id
+  posts  Post[]
+  edited Post[] @relation("edits")
+
+  @@map("users")
+}
+`,
+			`table users
+	id int @id
+end
+
+table posts
+	id        int @id
+	author_id int @reference("users", "id") @onDelete("CASCADE") @onUpdate("SET NULL")
+	editorId  int @nullable @reference("users", "id")
+end
+`,
+			[]string{},
+		},
+		{
+			"relation to the own model",
+			`
+model Employee {
+  id        Int        @id
+  managerId Int?
+  manager   Employee?  @relation("reports", fields: [managerId], references: [id])
+  reports   Employee[] @relation("reports")
+}
+`,
+			`table Employee
+	id        int @id
+	managerId int @nullable @reference("Employee", "id")
+end
+`,
+			[]string{},
+		},
+		{
+			"unsupported",
+			`
+enum Role {
+  USER
+  ADMIN
+}
+
+model User {
+  id    Int      @id
+  role  Role     @default(USER)
+  token String   @default(uuid())
+  tags  String[]
+
+  @@index([role, token])
+}
+`,
+			`table User
+	id    int    @id
+	role  string @default("USER")
+	token string
+end
+`,
+			[]string{
+				"Enum 'Role' is not supported",
+				"@@index of model 'User'",
+				"Default uuid() of 'token'",
+				"List field 'tags'",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imported, warnings, err := ImportPrisma(test.prisma)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if schema := Format(imported); schema != test.schema {
+				t.Errorf("schema:\n%s\nwant:\n%s", schema, test.schema)
+			}
+
+			if len(warnings) != len(test.warnings) {
+				t.Fatalf("warnings %q, want %d", warnings, len(test.warnings))
+			}
+			for i, warning := range test.warnings {
+				if !strings.Contains(warnings[i], warning) {
+					t.Errorf("warning %q does not mention %q", warnings[i], warning)
+				}
+			}
+		})
+	}
+}
+
+func TestImportPrismaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		prisma string
+		error  string
+	}{
+		{
+			"relation to an unknown model",
+			"model Post {\n  id Int @id\n  authorId Int\n  author User @relation(fields: [authorId], references: [id])\n}\n",
+			"User",
+		},
+		{
+			"unclosed model",
+			"model User {\n  id Int @id\n",
+			"Missing '}'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := ImportPrisma(test.prisma)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+// a model named after a reserved word is quoted in the generated sql
+func TestImportPrismaReservedModel(t *testing.T) {
+	imported, _, err := ImportPrisma(`
+datasource db {
+  provider = "postgresql"
+  url      = env("DATABASE_URL")
+}
+
+model User {
+  id Int @id
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, err := GenerateSQL(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, `CREATE TABLE "User"`) {
+		t.Errorf("table User is not quoted:\n%s", sql)
+	}
+}
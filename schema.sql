@@ -0,0 +1,4 @@
+CREATE TABLE t (
+	a JSONB NOT NULL
+);
+
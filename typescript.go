@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// types written as "datetime" follow the --datetime option, raw sql types
+// can hold anything
+var tsTypes = map[string]string{
+	"int":         "number",
+	"bigint":      "number",
+	"smallint":    "number",
+	"float":       "number",
+	"decimal":     "string",
+	"string":      "string",
+	"char":        "string",
+	"text":        "string",
+	"uuid":        "string",
+	"bool":        "boolean",
+	"boolean":     "boolean",
+	"datetime":    "datetime",
+	"date":        "datetime",
+	"timestamptz": "datetime",
+	"time":        "string",
+	"blob":        "Uint8Array",
+	"bytes":       "Uint8Array",
+	"json":        "unknown",
+	"raw":         "unknown",
+}
+
+// GenerateTypeScript writes one interface per table, foreign keys point at
+// the type of the colmun they reference so both stay the same
+func GenerateTypeScript(ast *AST, cfg *Config) (string, error) {
+	interfaces := []string{}
+
+	for _, table := range ast.Tables {
+		fields := []string{}
+
+		for _, colmun := range table.Colmuns {
+			tsType, err := tsColmunType(ast, table, colmun, cfg)
+			if err != nil {
+				return "", err
+			}
+
+			if _, nullable := (*colmun.Attributes)["nullable"]; nullable {
+				tsType += " | null"
+			}
+
+			fields = append(fields, fmt.Sprintf("  %s: %s;", colmun.Name, tsType))
+		}
+
+		interfaces = append(interfaces, fmt.Sprintf(
+			"/** A row of the table %s */\nexport interface %s {\n%s\n}\n",
+			table.Name,
+			goName(table.Name),
+			strings.Join(fields, "\n"),
+		))
+	}
+
+	return "// Code generated by sql-mi. DO NOT EDIT.\n\n" + strings.Join(interfaces, "\n"), nil
+}
+
+func tsColmunType(ast *AST, table *TabelAST, colmun *ColmunAST, cfg *Config) (string, error) {
+	for _, ref := range table.References {
+		if ref.SourceCol != colmun.Name {
+			continue
+		}
+
+		target := findTable(ast, ref.TargetTable)
+		if target != nil && findColmun(target, ref.TargetCol) != nil {
+			return fmt.Sprintf("%s[%q]", goName(target.Name), ref.TargetCol), nil
+		}
+	}
+
+	tsType, exists := tsTypes[colmun.Data_type]
+	if !exists {
+		return "", fmt.Errorf("Error: Type '%s' of colmun '%s' has no typescript type", colmun.Data_type, colmun.Name)
+	}
+
+	if tsType == "datetime" {
+		tsType = cfg.Datetime
+	}
+	return tsType, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypeScript(t *testing.T) {
+	schema := parseSchema(t, "postgresql", `
+table users
+	id int @id
+	name string
+end
+table posts
+	id int @id
+	user_id int @reference("users", "id")
+	editor_id int @nullable @reference("users", "id")
+	published_at datetime @nullable
+	day date
+	at time
+	active bool
+	body bytes
+	meta json
+	span `+"`INTERVAL`"+`
+end
+`)
+
+	tests := []struct {
+		datetime string
+		want     string
+	}{
+		{"string", `// Code generated by sql-mi. DO NOT EDIT.
+
+/** A row of the table users */
+export interface Users {
+  id: number;
+  name: string;
+}
+
+/** A row of the table posts */
+export interface Posts {
+  id: number;
+  user_id: Users["id"];
+  editor_id: Users["id"] | null;
+  published_at: string | null;
+  day: string;
+  at: string;
+  active: boolean;
+  body: Uint8Array;
+  meta: unknown;
+  span: unknown;
+}
+`},
+		{"Date", `// Code generated by sql-mi. DO NOT EDIT.
+
+/** A row of the table users */
+export interface Users {
+  id: number;
+  name: string;
+}
+
+/** A row of the table posts */
+export interface Posts {
+  id: number;
+  user_id: Users["id"];
+  editor_id: Users["id"] | null;
+  published_at: Date | null;
+  day: Date;
+  at: string;
+  active: boolean;
+  body: Uint8Array;
+  meta: unknown;
+  span: unknown;
+}
+`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.datetime, func(t *testing.T) {
+			src, err := GenerateTypeScript(schema, &Config{Datetime: test.datetime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", src, test.want)
+			}
+		})
+	}
+}
+
+// every logical type has a typescript type
+func TestTsTypes(t *testing.T) {
+	initValues()
+
+	for _, name := range typeNames() {
+		if _, exists := tsTypes[name]; !exists {
+			t.Errorf("type %q has no typescript type", name)
+		}
+	}
+}
+
+func TestParseGenerateArgsTypeScript(t *testing.T) {
+	_, err := ParseGenerateArgs([]string{"--target", "typescript", "--datetime", "number", "schema.sqmi"})
+	if err == nil || !strings.Contains(err.Error(), "Unknown datetime type 'number', expected string or Date") {
+		t.Errorf("error %v, want an unknown datetime type", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// watchGenerate runs generate every time one of the schema files changes.
+// Files are polled instead of using inotify so it behaves the same on every
+// system, and outputs are only written when the sql actually changed
+func watchGenerate(cfg *Config) int {
+	written := map[string]string{}
+	watched := []string{}
+
+	for {
+		ast, err := loadSchemas(cfg.InputFilePaths)
+		if err != nil {
+			printError(err)
+		} else {
+			// imports are only known after parsing
+			watched = ast.Files
+			regenerate(cfg, ast, written)
+		}
+
+		waitForChange(cfg, watched)
+		fmt.Fprintf(os.Stderr, "Change detected, regenerating\n")
+	}
+}
+
+func regenerate(cfg *Config, ast *AST, written map[string]string) {
+	outputs, err := generateOutputs(cfg, ast)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	for _, path := range sortedKeys(outputs) {
+		sql := outputs[path]
+
+		previous, exists := written[path]
+		if !exists && path != "-" {
+			content, err := os.ReadFile(path)
+			if err == nil {
+				previous, exists = string(content), true
+			}
+		}
+
+		if exists && previous == sql {
+			continue
+		}
+
+		err = writeOutput(path, sql)
+		if err != nil {
+			printError(err)
+			continue
+		}
+		written[path] = sql
+
+		if path != "-" {
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+		}
+	}
+}
+
+// waitForChange blocks until a watched file is modified, created or removed,
+// the input is expanded again on every poll to notice new files in a directory
+func waitForChange(cfg *Config, watched []string) {
+	before := fileStamps(cfg.InputFilePaths, watched)
+
+	for {
+		time.Sleep(cfg.WatchInterval)
+
+		after := fileStamps(cfg.InputFilePaths, watched)
+		if len(after) != len(before) {
+			return
+		}
+		for path, stamp := range after {
+			if before[path] != stamp {
+				return
+			}
+		}
+	}
+}
+
+func fileStamps(inputs []string, watched []string) map[string]string {
+	paths := append([]string{}, watched...)
+
+	for _, input := range inputs {
+		expanded, err := ExpandInputs(input)
+		if err == nil {
+			paths = append(paths, expanded...)
+		}
+	}
+
+	stamps := map[string]string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			stamps[path] = "missing"
+			continue
+		}
+		stamps[path] = fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+	}
+
+	return stamps
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegenerate(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "schema.sql")
+	cfg := &Config{OutputFilePath: output}
+	written := map[string]string{}
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name    string
+		schema  string
+		written bool
+	}{
+		{"first run", "table users\n\tid int\nend\n", true},
+		{"same sql", "table users\n\tid    int\nend\n", false},
+		{"changed sql", "table users\n\tid int\n\tname string\nend\n", true},
+	}
+
+	for _, step := range steps {
+		os.Chtimes(output, old, old)
+
+		regenerate(cfg, parseSchema(t, "sqlite", step.schema), written)
+
+		info, err := os.Stat(output)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if rewritten := !info.ModTime().Equal(old); rewritten != step.written {
+			t.Errorf("%s: output rewritten %v, want %v", step.name, rewritten, step.written)
+		}
+	}
+}
+
+// an output already holding the sql is left alone on the first run too
+func TestRegenerateKeepsExistingOutput(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "schema.sql")
+	schema := parseSchema(t, "sqlite", "table users\n\tid int\nend\n")
+
+	sql, err := GenerateSQL(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSchema(t, dir, "schema.sql", sql)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(output, old, old)
+
+	regenerate(&Config{OutputFilePath: output}, schema, map[string]string{})
+
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Error("unchanged output was rewritten")
+	}
+}
+
+func TestWaitForChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(dir string) error
+	}{
+		{"schema edited", func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "schema", "main.sqmi"), []byte("table users\n\tid int @id\nend\n"), 0644)
+		}},
+		{"imported file edited", func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "shared.sqmi"), []byte("table tags\n\tid int @id\nend\n"), 0644)
+		}},
+		{"file added to the directory", func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "schema", "posts.sqmi"), []byte("table posts\n\tid int\nend\n"), 0644)
+		}},
+		{"imported file removed", func(dir string) error {
+			return os.Remove(filepath.Join(dir, "shared.sqmi"))
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			main := writeSchema(t, dir, "schema/main.sqmi", "table users\n\tid int\nend\n")
+			shared := writeSchema(t, dir, "shared.sqmi", "table tags\n\tid int\nend\n")
+			cfg := &Config{InputFilePaths: []string{filepath.Join(dir, "schema")}, WatchInterval: 5 * time.Millisecond}
+
+			changed := make(chan bool)
+			go func() {
+				waitForChange(cfg, []string{main, shared})
+				changed <- true
+			}()
+
+			select {
+			case <-changed:
+				t.Fatal("returned before any change")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			err := test.change(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-changed:
+			case <-time.After(2 * time.Second):
+				t.Fatal("the change was not noticed")
+			}
+		})
+	}
+}